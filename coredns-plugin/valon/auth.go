@@ -0,0 +1,129 @@
+package valon
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// signatureTimestampWindow bounds how far a signed request's timestamp may
+// drift from server time before it is rejected, limiting the replay window
+// an attacker gets even before the nonce cache is consulted.
+const signatureTimestampWindow = 60 * time.Second
+
+// maxTrackedNonces bounds the replay-protection cache so a long-running
+// Discovery Role doesn't grow it without limit; oldest nonces are evicted
+// first once the limit is reached.
+const maxTrackedNonces = 4096
+
+// nonceCache is a bounded, insertion-ordered set of "<pubkey>:<nonce>"
+// entries used to reject replayed signed requests.
+type nonceCache struct {
+	mu    sync.Mutex
+	seen  map[string]struct{}
+	order []string
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{seen: make(map[string]struct{})}
+}
+
+// seenBefore records key if it is new and reports whether it was already
+// present. Callers should reject the request when it returns true.
+func (c *nonceCache) seenBefore(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.seen[key]; ok {
+		return true
+	}
+
+	c.seen[key] = struct{}{}
+	c.order = append(c.order, key)
+	if len(c.order) > maxTrackedNonces {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.seen, oldest)
+	}
+	return false
+}
+
+// verifySignedRequest authenticates a signed endpoint update. It checks the
+// timestamp window, rejects replayed nonces, fetches the peer's identity
+// public key from etcd, and verifies the Ed25519 signature over the
+// canonical serialization of the request.
+func (v *Valon) verifySignedRequest(req DDNSEndpointRequest) error {
+	if req.Timestamp == 0 || req.Nonce == "" || req.Signature == "" {
+		return fmt.Errorf("signed request missing timestamp, nonce, or signature")
+	}
+
+	age := time.Since(time.Unix(req.Timestamp, 0))
+	if age < 0 {
+		age = -age
+	}
+	if age > signatureTimestampWindow {
+		return fmt.Errorf("timestamp outside of %v window", signatureTimestampWindow)
+	}
+
+	nonceKey := req.PubKey + ":" + req.Nonce
+	if v.nonces.seenBefore(nonceKey) {
+		return fmt.Errorf("nonce already used")
+	}
+
+	identityPub, err := v.getIdentityPubKey(req.PubKey)
+	if err != nil {
+		return fmt.Errorf("failed to look up identity key: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(req.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	if !ed25519.Verify(identityPub, canonicalEndpointMessage(req), sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+
+	return nil
+}
+
+// canonicalEndpointMessage builds the exact byte sequence that valonctl
+// signs when registering an endpoint, so server and client must agree on
+// field order and separators.
+func canonicalEndpointMessage(req DDNSEndpointRequest) []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%s\n%d\n%s",
+		req.PubKey, req.LANEndpoint, req.Alias, req.Timestamp, req.Nonce))
+}
+
+// getIdentityPubKey fetches and decodes the Ed25519 identity public key
+// registered for pubkey at /valon/peers/<pubkey>/identity_pub.
+func (v *Valon) getIdentityPubKey(pubkey string) (ed25519.PublicKey, error) {
+	if v.etcdClient == nil {
+		return nil, fmt.Errorf("signature-based auth requires the etcd backend")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	key := fmt.Sprintf("/valon/peers/%s/identity_pub", pubkey)
+	resp, err := v.etcdClient.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("etcd get failed: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("no identity_pub registered for peer")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(string(resp.Kvs[0].Value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity_pub encoding: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid identity_pub length: %d", len(raw))
+	}
+
+	return ed25519.PublicKey(raw), nil
+}
@@ -7,13 +7,54 @@ import (
 
 // PeerInfo represents cached information about a WireGuard peer.
 type PeerInfo struct {
-	PubKey        string    // Base64 WireGuard public key
-	WgIP          string    // WireGuard IP address (e.g., "100.64.0.1")
-	LANEndpoint   string    // LAN endpoint (e.g., "192.168.1.100:51820") - from DDNS API
-	NATEndpoint   string    // NAT endpoint (e.g., "203.0.113.1:51820") - from wg show observation
+	PubKey       string // Base64 WireGuard public key
+	Alias        string // User-friendly alias, set via `valonctl peer add --alias` or DDNS registration
+	WgIP         string // WireGuard IP address (e.g., "100.64.0.1")
+	LANEndpoint  string // LAN endpoint (e.g., "192.168.1.100:51820") - from DDNS API
+	NATEndpoint  string // NAT endpoint (e.g., "203.0.113.1:51820") - from wg show observation
+	StunEndpoint string // STUN-reflexive endpoint (e.g., "203.0.113.1:55123") - self-reported via netcheck
+
+	// WgIPv6, LANEndpointV6, and NATEndpointV6 are the IPv6 counterparts of
+	// WgIP/LANEndpoint/NATEndpoint, answered by handleAAAA instead of
+	// handleA. Any of them may be empty even when the IPv4 field is set -
+	// dual-stack is per-peer, not all-or-nothing.
+	WgIPv6        string
+	LANEndpointV6 string
+	NATEndpointV6 string
+	NATType       string    // NAT classification reported by netcheck (open, eim, edm, symmetric, unknown)
 	LastHandshake time.Time // Last WireGuard handshake time
 	UpdatedAt     time.Time // Last update time
 	dirty         bool      // Needs etcd sync
+
+	// Role is this peer's declared role (e.g. "relay"), set via
+	// `valonctl peer add --role` and stored at /valon/peers/<pubkey>/role.
+	Role string
+
+	// RelayedVia is the pubkey of the relay peer currently standing in for
+	// this peer's endpoint, set by checkStuckPeers once direct connectivity
+	// has failed for RelayStuckThreshold. Empty means this peer's
+	// endpoints/nated is its own observed endpoint.
+	RelayedVia string
+
+	// IsStatic marks a WireGuard-only peer (a cloud router, vendor
+	// appliance, or off-mesh site-to-site link) that does not run valonctl
+	// and so never registers via DDNS. Endpoint holds its pinned address;
+	// the DDNS watch loop ignores registration attempts for it, and the
+	// WireGuard reconfigure path applies Endpoint directly instead of
+	// LANEndpoint/NATEndpoint.
+	IsStatic bool
+	Endpoint string
+
+	// Lazy peer materialization state (only meaningful when Valon.LazyPeers is set).
+	Installed      bool      // whether the peer is currently programmed into the kernel WireGuard device
+	LastActivity   time.Time // last time the peer was observed to be in active use
+	DeprogrammedAt time.Time // last time the peer was removed from the WireGuard device for being idle
+
+	// etcdRevision is the highest etcd ModRevision observed for any of this
+	// peer's keys (via the initial load or the watch). The periodic
+	// reconciliation sync uses it as a compare-and-swap guard so it never
+	// clobbers a write newer than what we last observed.
+	etcdRevision int64
 }
 
 // PeerCache is a thread-safe in-memory cache for peer information.
@@ -83,3 +124,42 @@ func (c *PeerCache) Count() int {
 	defer c.mu.RUnlock()
 	return len(c.peers)
 }
+
+// PeerDeltaOp identifies the kind of change a PeerDelta represents.
+type PeerDeltaOp string
+
+const (
+	PeerDeltaPut    PeerDeltaOp = "put"
+	PeerDeltaDelete PeerDeltaOp = "delete"
+)
+
+// PeerDelta is a single add/update/remove applied by ApplyDelta, as decoded
+// from a netmap coordinator poll response (see netmap.go).
+type PeerDelta struct {
+	Op     PeerDeltaOp
+	Pubkey string
+	Peer   *PeerInfo // nil when Op is PeerDeltaDelete
+}
+
+// ApplyDelta applies a batch of deltas under a single write lock, so a
+// concurrent ServeDNS reader never observes a partially-applied batch -
+// the netmap long-poll client's equivalent of the direct etcd watch path's
+// one-event-at-a-time Update/Set/Delete calls in applyPeerEvent.
+func (c *PeerCache) ApplyDelta(deltas []PeerDelta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, d := range deltas {
+		switch d.Op {
+		case PeerDeltaDelete:
+			delete(c.peers, d.Pubkey)
+		case PeerDeltaPut:
+			if d.Peer == nil {
+				continue
+			}
+			d.Peer.UpdatedAt = now
+			c.peers[d.Pubkey] = d.Peer
+		}
+	}
+}
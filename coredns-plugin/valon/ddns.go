@@ -8,13 +8,25 @@ import (
 	"net"
 	"net/http"
 	"time"
+
+	"github.com/pabotesu/valon/coredns-plugin/valon/wgcfg"
 )
 
 // DDNSEndpointRequest represents the request body for endpoint registration.
 type DDNSEndpointRequest struct {
-	PubKey      string `json:"pubkey"`       // WireGuard public key (Base64)
-	LANEndpoint string `json:"lan_endpoint"` // LAN endpoint (IP:PORT)
-	Alias       string `json:"alias"`        // Optional: CNAME alias (e.g., "alice-macbook")
+	PubKey       string `json:"pubkey"`                  // WireGuard public key (Base64)
+	LANEndpoint  string `json:"lan_endpoint"`            // LAN endpoint (IP:PORT)
+	Alias        string `json:"alias"`                   // Optional: CNAME alias (e.g., "alice-macbook")
+	StunEndpoint string `json:"stun_endpoint,omitempty"` // Optional: STUN-reflexive endpoint (IP:PORT) from netcheck
+	NatType      string `json:"nat_type,omitempty"`      // Optional: NAT classification from netcheck (open, eim, edm, symmetric)
+
+	// Timestamp, Nonce, and Signature are only required when the server's
+	// DDNSAuthMode is "signature" or "both". Signature is the base64 Ed25519
+	// signature over canonicalEndpointMessage, produced with the identity key
+	// registered at /valon/peers/<pubkey>/identity_pub (see auth.go).
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 // DDNSResponse represents the API response.
@@ -71,14 +83,25 @@ func (v *Valon) handleEndpointUpdate(w http.ResponseWriter, r *http.Request) {
 		v.sendError(w, http.StatusBadRequest, "pubkey is required")
 		return
 	}
+	if _, err := wgcfg.ParseNodePublicBase64(req.PubKey); err != nil {
+		v.sendError(w, http.StatusBadRequest, "pubkey is not a valid WireGuard public key")
+		return
+	}
 
-	// Access control: verify source IP
-	clientIP := extractClientIP(r)
-	if !v.isAuthorized(clientIP, req.PubKey) {
+	// Access control: source IP match (the original behavior), Ed25519
+	// signature verification (roam-safe), or either, per v.DDNSAuthMode.
+	if !v.isAuthorizedRequest(r, req) {
 		v.sendError(w, http.StatusForbidden, "Not authorized to modify this peer")
 		return
 	}
 
+	// Static (WireGuard-only) peers don't run valonctl and have a pinned
+	// Endpoint set by the operator, so they never register via DDNS.
+	if p := v.cache.Get(req.PubKey); p != nil && p.IsStatic {
+		v.sendError(w, http.StatusForbidden, "Peer is static; endpoint is pinned and not DDNS-registrable")
+		return
+	}
+
 	// Validate LAN endpoint format (allow "0.0.0.0:0" for offline, or empty for removal)
 	if req.LANEndpoint != "" && req.LANEndpoint != "0.0.0.0:0" {
 		if _, _, err := net.SplitHostPort(req.LANEndpoint); err != nil {
@@ -100,6 +123,14 @@ func (v *Valon) handleEndpointUpdate(w http.ResponseWriter, r *http.Request) {
 			}
 			p.dirty = true
 		}
+		if req.StunEndpoint != "" && p.StunEndpoint != req.StunEndpoint {
+			p.StunEndpoint = req.StunEndpoint
+			p.dirty = true
+		}
+		if req.NatType != "" && p.NATType != req.NatType {
+			p.NATType = req.NatType
+			p.dirty = true
+		}
 		p.UpdatedAt = time.Now()
 	})
 
@@ -110,7 +141,9 @@ func (v *Valon) handleEndpointUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register alias if provided
-	if req.Alias != "" {
+	if req.Alias != "" && v.etcdClient == nil {
+		log.Printf("[valon] DDNS: Alias registration requires the etcd backend, ignoring alias %q", req.Alias)
+	} else if req.Alias != "" {
 		dnsLabel, err := pubkeyToDnsLabel(req.PubKey)
 		if err != nil {
 			log.Printf("[valon] DDNS: Failed to convert pubkey to DNS label: %v", err)
@@ -161,6 +194,10 @@ func (v *Valon) handleEndpointDelete(w http.ResponseWriter, r *http.Request) {
 		v.sendError(w, http.StatusBadRequest, "pubkey is required")
 		return
 	}
+	if _, err := wgcfg.ParseNodePublicBase64(req.PubKey); err != nil {
+		v.sendError(w, http.StatusBadRequest, "pubkey is not a valid WireGuard public key")
+		return
+	}
 
 	// Access control: Only Discovery Role can delete peers
 	clientIP := extractClientIP(r)
@@ -174,11 +211,14 @@ func (v *Valon) handleEndpointDelete(w http.ResponseWriter, r *http.Request) {
 	v.cache.Delete(req.PubKey)
 
 	// Delete alias from etcd
-	ctx := context.Background()
-	aliasKey := fmt.Sprintf("/valon/aliases/%s", req.PubKey)
-	_, err := v.etcdClient.Delete(ctx, aliasKey)
-	if err != nil {
-		log.Printf("[valon] DDNS: Failed to delete alias for %s: %v", req.PubKey, err)
+	if v.etcdClient == nil {
+		log.Printf("[valon] DDNS: Alias deletion requires the etcd backend, skipping")
+	} else {
+		ctx := context.Background()
+		aliasKey := fmt.Sprintf("/valon/aliases/%s", req.PubKey)
+		if _, err := v.etcdClient.Delete(ctx, aliasKey); err != nil {
+			log.Printf("[valon] DDNS: Failed to delete alias for %s: %v", req.PubKey, err)
+		}
 	}
 
 	log.Printf("[valon] DDNS: Peer %s deleted from cache and alias removed", req.PubKey)
@@ -214,6 +254,37 @@ func extractClientIP(r *http.Request) string {
 	return host
 }
 
+// isAuthorizedRequest authorizes an /api/endpoint request according to
+// v.DDNSAuthMode: "ip" checks the source IP (isAuthorized), "signature"
+// requires a valid Ed25519 signature (verifySignedRequest), and "both"
+// accepts either so a roamed peer isn't locked out while its IP-based
+// fallback is still being migrated off.
+func (v *Valon) isAuthorizedRequest(r *http.Request, req DDNSEndpointRequest) bool {
+	switch v.DDNSAuthMode {
+	case "signature":
+		if err := v.verifySignedRequest(req); err != nil {
+			log.Printf("[valon] DDNS: signature authorization failed: %v", err)
+			return false
+		}
+		return true
+
+	case "both":
+		clientIP := extractClientIP(r)
+		if v.isAuthorized(clientIP, req.PubKey) {
+			return true
+		}
+		if err := v.verifySignedRequest(req); err != nil {
+			log.Printf("[valon] DDNS: signature authorization failed: %v", err)
+			return false
+		}
+		return true
+
+	default: // "ip"
+		clientIP := extractClientIP(r)
+		return v.isAuthorized(clientIP, req.PubKey)
+	}
+}
+
 // isAuthorized checks if the client IP is authorized to modify the specified peer.
 // Returns true if:
 // 1. Client is Discovery Role (self.wgIP)
@@ -0,0 +1,236 @@
+package valon
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// dnsSDServices lists the RFC 6763 service types VALON advertises for
+// browsing. "_wireguard._udp" enumerates every cached peer; the "-lan"/
+// "-nat" variants let a browsing client ask for only LAN-reachable or only
+// NAT'd peers, mirroring the LAN/NAT endpoint split handleSRV already
+// serves for direct instance lookups.
+var dnsSDServices = []string{"_wireguard._udp", "_valon-lan._udp", "_valon-nat._udp"}
+
+// dnsSDBrowseService reports whether name is a DNS-SD browsing query of the
+// form "<service>.<zone>" (e.g. "_wireguard._udp.valon.internal."), and if
+// so, which service it names.
+func dnsSDBrowseService(name, zone string) (string, bool) {
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(name, zone), ".")
+	for _, svc := range dnsSDServices {
+		if trimmed == svc {
+			return svc, true
+		}
+	}
+	return "", false
+}
+
+// splitDNSSDInstance reports whether name is a DNS-SD instance query of the
+// form "<label>.<service>.<zone>" (e.g.
+// "mfrggzdf....._wireguard._udp.valon.internal."), the RFC 6763
+// instance-name ordering - the reverse of the legacy
+// "_wireguard._udp.<label>.<zone>" shape handleSRV serves for plain peer
+// endpoint lookups.
+func splitDNSSDInstance(name, zone string) (label, service string, ok bool) {
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(name, zone), ".")
+	for _, svc := range dnsSDServices {
+		suffix := "." + svc
+		if strings.HasSuffix(trimmed, suffix) {
+			if label = strings.TrimSuffix(trimmed, suffix); label != "" {
+				return label, svc, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// serviceMatchesPeer reports whether peer should be enumerated under svc:
+// the base service lists every cached peer, while the LAN/NAT variants
+// only list peers that currently have that endpoint type.
+func serviceMatchesPeer(svc string, peer *PeerInfo) bool {
+	switch svc {
+	case "_valon-lan._udp":
+		return peer.LANEndpoint != ""
+	case "_valon-nat._udp":
+		return peer.NATEndpoint != ""
+	default:
+		return true
+	}
+}
+
+// instanceEndpoint picks the endpoint a DNS-SD instance SRV record should
+// advertise for svc: the LAN/NAT variants are specific to that endpoint
+// type, while the base service falls back across LAN, NAT, then STUN, same
+// as valonctl's showconf peer endpoint fallback.
+func instanceEndpoint(svc string, peer *PeerInfo) string {
+	switch svc {
+	case "_valon-lan._udp":
+		return peer.LANEndpoint
+	case "_valon-nat._udp":
+		return peer.NATEndpoint
+	default:
+		if peer.LANEndpoint != "" {
+			return peer.LANEndpoint
+		}
+		if peer.NATEndpoint != "" {
+			return peer.NATEndpoint
+		}
+		return peer.StunEndpoint
+	}
+}
+
+// handleDNSSDBrowse handles a PTR query against a DNS-SD service browsing
+// name (e.g. "_wireguard._udp.valon.internal."), answering with one PTR
+// per cached peer that matches svc, pointing at that peer's instance name.
+func (v Valon) handleDNSSDBrowse(w dns.ResponseWriter, r *dns.Msg, state request.Request, svc string) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	for _, peer := range v.cache.GetAll() {
+		if !serviceMatchesPeer(svc, peer) {
+			continue
+		}
+
+		label, err := pubkeyToDnsLabel(peer.PubKey)
+		if err != nil {
+			continue
+		}
+
+		instance := fmt.Sprintf("%s.%s.%s", label, svc, v.Zone)
+		m.Answer = append(m.Answer, &dns.PTR{
+			Hdr: dns.RR_Header{
+				Name:   state.Name(),
+				Rrtype: dns.TypePTR,
+				Class:  dns.ClassINET,
+				Ttl:    30,
+			},
+			Ptr: instance,
+		})
+	}
+
+	if len(m.Answer) == 0 {
+		log.Printf("[valon] No peers to browse for service: %s", svc)
+		return v.nxdomain(w, r)
+	}
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// handleDNSSDInstanceSRV handles a DNS-SD instance SRV query (e.g.
+// "<label>._wireguard._udp.valon.internal."), answering with the peer's
+// endpoint for svc and a target of "<label>.<zone>" (its plain A record).
+func (v Valon) handleDNSSDInstanceSRV(w dns.ResponseWriter, r *dns.Msg, state request.Request, label, svc string) (int, error) {
+	pubkey, err := dnsLabelToPubkey(label)
+	if err != nil {
+		log.Printf("[valon] Invalid DNS-SD instance label: %s (%v)", label, err)
+		return v.nxdomain(w, r)
+	}
+
+	peer := v.cache.Get(pubkey)
+	if peer == nil {
+		log.Printf("[valon] No data found in cache for pubkey: %s", pubkey)
+		return v.nxdomain(w, r)
+	}
+
+	endpoint := instanceEndpoint(svc, peer)
+	if endpoint == "" {
+		return v.nxdomain(w, r)
+	}
+
+	_, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		log.Printf("[valon] Invalid endpoint format for %s: %s", pubkey, endpoint)
+		return v.nxdomain(w, r)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return v.nxdomain(w, r)
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	target := fmt.Sprintf("%s.%s", label, v.Zone)
+	m.Answer = append(m.Answer, &dns.SRV{
+		Hdr: dns.RR_Header{
+			Name:   state.Name(),
+			Rrtype: dns.TypeSRV,
+			Class:  dns.ClassINET,
+			Ttl:    30,
+		},
+		Priority: 0,
+		Weight:   0,
+		Port:     uint16(port),
+		Target:   target,
+	})
+
+	if peer.WgIP != "" {
+		if ip := net.ParseIP(peer.WgIP); ip != nil {
+			m.Extra = append(m.Extra, &dns.A{
+				Hdr: dns.RR_Header{
+					Name:   target,
+					Rrtype: dns.TypeA,
+					Class:  dns.ClassINET,
+					Ttl:    30,
+				},
+				A: ip.To4(),
+			})
+		}
+	}
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// handleDNSSDInstanceTXT handles a DNS-SD instance TXT query (e.g.
+// "<label>._wireguard._udp.valon.internal."), answering with key/value
+// attributes describing the peer.
+func (v Valon) handleDNSSDInstanceTXT(w dns.ResponseWriter, r *dns.Msg, state request.Request, label, svc string) (int, error) {
+	pubkey, err := dnsLabelToPubkey(label)
+	if err != nil {
+		log.Printf("[valon] Invalid DNS-SD instance label: %s (%v)", label, err)
+		return v.nxdomain(w, r)
+	}
+
+	peer := v.cache.Get(pubkey)
+	if peer == nil {
+		log.Printf("[valon] No data found in cache for pubkey: %s", pubkey)
+		return v.nxdomain(w, r)
+	}
+
+	txt := []string{
+		fmt.Sprintf("pubkey=%s", peer.PubKey),
+		fmt.Sprintf("wg_ip=%s", peer.WgIP),
+	}
+	if peer.Alias != "" {
+		txt = append(txt, fmt.Sprintf("alias=%s", peer.Alias))
+	}
+	if !peer.LastHandshake.IsZero() {
+		txt = append(txt, fmt.Sprintf("last_handshake=%s", peer.LastHandshake.UTC().Format("2006-01-02T15:04:05Z")))
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.Answer = append(m.Answer, &dns.TXT{
+		Hdr: dns.RR_Header{
+			Name:   state.Name(),
+			Rrtype: dns.TypeTXT,
+			Class:  dns.ClassINET,
+			Ttl:    30,
+		},
+		Txt: txt,
+	})
+
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
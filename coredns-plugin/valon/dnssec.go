@@ -0,0 +1,430 @@
+package valon
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// dnssecSignatureValidity is how long a synthesized RRSIG is valid for.
+// Combined with dnssecInceptionSkew, it bounds how long a cached signature
+// (see sigCache) can be reused before it must be regenerated.
+const dnssecSignatureValidity = 7 * 24 * time.Hour
+
+// dnssecInceptionSkew backdates RRSIG.Inception so a slightly-behind
+// resolver clock doesn't reject an otherwise-valid signature.
+const dnssecInceptionSkew = 3 * time.Hour
+
+// maxSigCacheEntries bounds the signature cache so a long-running Discovery
+// Role doesn't grow it without limit; oldest entries are evicted first,
+// mirroring the nonceCache eviction policy in auth.go.
+const maxSigCacheEntries = 4096
+
+// dnssecSigner holds the zone's signing keys and the signature cache, and
+// does the actual work of turning an RRset into an RRSIG.
+type dnssecSigner struct {
+	zone string
+
+	zsk       *dns.DNSKEY
+	zskSigner crypto.Signer
+
+	// ksk and kskSigner are nil unless a separate key-signing key was
+	// configured (DNSSECKSKPath); in that case ksk signs the DNSKEY RRset
+	// and zsk signs everything else, the usual split-key setup.
+	ksk       *dns.DNSKEY
+	kskSigner crypto.Signer
+
+	cache *sigCache
+}
+
+// newDNSSECSigner loads the ZSK (and, if kskPath is non-empty, a separate
+// KSK) from PEM-encoded PKCS#8 private key files and builds their DNSKEY
+// records.
+func newDNSSECSigner(zone, zskPath, kskPath string) (*dnssecSigner, error) {
+	zskSigner, err := loadSignerKey(zskPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading ZSK: %w", err)
+	}
+
+	// With no separate KSK, the ZSK is the zone's only key, so it carries
+	// the Secure Entry Point bit a resolver looks for when building the
+	// chain of trust from a parent DS record.
+	zskFlags := uint16(256)
+	if kskPath == "" {
+		zskFlags = 257
+	}
+	zsk, err := buildDNSKEY(zone, zskSigner, zskFlags)
+	if err != nil {
+		return nil, fmt.Errorf("building ZSK DNSKEY: %w", err)
+	}
+
+	s := &dnssecSigner{
+		zone:      zone,
+		zsk:       zsk,
+		zskSigner: zskSigner,
+		cache:     newSigCache(),
+	}
+
+	if kskPath != "" {
+		kskSigner, err := loadSignerKey(kskPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading KSK: %w", err)
+		}
+		ksk, err := buildDNSKEY(zone, kskSigner, 257)
+		if err != nil {
+			return nil, fmt.Errorf("building KSK DNSKEY: %w", err)
+		}
+		s.ksk = ksk
+		s.kskSigner = kskSigner
+	}
+
+	return s, nil
+}
+
+// loadSignerKey reads a PEM-encoded PKCS#8 private key and returns it as a
+// crypto.Signer, accepting only the two algorithms VALON publishes DNSKEYs
+// for: Ed25519 and ECDSA P-256 (the inputs to RRSIG.Algorithm ED25519 and
+// ECDSAP256SHA256 respectively).
+func loadSignerKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing PKCS8 key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported ECDSA curve %s: must be P-256", k.Curve.Params().Name)
+		}
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T: must be Ed25519 or ECDSA P-256", key)
+	}
+}
+
+// buildDNSKEY constructs the DNSKEY record published for signer's public
+// key, encoding it per RFC 8080 (Ed25519) or RFC 6605 (ECDSA P-256).
+func buildDNSKEY(zone string, signer crypto.Signer, flags uint16) (*dns.DNSKEY, error) {
+	dnskey := &dns.DNSKEY{
+		Hdr:      dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:    flags,
+		Protocol: 3,
+	}
+
+	switch pub := signer.Public().(type) {
+	case ed25519.PublicKey:
+		dnskey.Algorithm = dns.ED25519
+		dnskey.PublicKey = base64.StdEncoding.EncodeToString(pub)
+	case *ecdsa.PublicKey:
+		dnskey.Algorithm = dns.ECDSAP256SHA256
+		buf := make([]byte, 64)
+		pub.X.FillBytes(buf[:32])
+		pub.Y.FillBytes(buf[32:])
+		dnskey.PublicKey = base64.StdEncoding.EncodeToString(buf)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return dnskey, nil
+}
+
+// cds builds the CDS record (RFC 7344) advertising the KSK's digest for a
+// parent zone to pick up, or nil if no KSK is configured.
+func (s *dnssecSigner) cds() *dns.CDS {
+	if s.ksk == nil {
+		return nil
+	}
+	ds := s.ksk.ToDS(dns.SHA256)
+	ds.Hdr.Rrtype = dns.TypeCDS
+	return &dns.CDS{DS: *ds}
+}
+
+// cdnskey builds the CDNSKEY record (RFC 7344) mirroring the KSK, or nil if
+// no KSK is configured.
+func (s *dnssecSigner) cdnskey() *dns.CDNSKEY {
+	if s.ksk == nil {
+		return nil
+	}
+	cdnskey := &dns.CDNSKEY{DNSKEY: *s.ksk}
+	cdnskey.Hdr.Rrtype = dns.TypeCDNSKEY
+	return cdnskey
+}
+
+// signingKey returns the key that signs rrtype's RRset: the KSK for
+// DNSKEY itself (when a separate KSK is configured), the ZSK for
+// everything else.
+func (s *dnssecSigner) signingKey(rrtype uint16) (*dns.DNSKEY, crypto.Signer) {
+	if rrtype == dns.TypeDNSKEY && s.ksk != nil {
+		return s.ksk, s.kskSigner
+	}
+	return s.zsk, s.zskSigner
+}
+
+// sign produces (or reuses a cached) RRSIG covering rrset, an RRset that
+// must all share the same owner name, type, and TTL.
+func (s *dnssecSigner) sign(rrset []dns.RR, ttl uint32) *dns.RRSIG {
+	if len(rrset) == 0 {
+		return nil
+	}
+	owner := rrset[0].Header().Name
+	rtype := rrset[0].Header().Rrtype
+	key, signer := s.signingKey(rtype)
+
+	cacheKey := sigCacheKey(rtype, owner, ttl, rrset)
+	if cached := s.cache.get(cacheKey); cached != nil && time.Now().Before(time.Unix(int64(cached.Expiration), 0)) {
+		dnssecCacheHits.Inc()
+		sig := *cached
+		return &sig
+	}
+	dnssecCacheMisses.Inc()
+
+	start := time.Now()
+	rrsig := &dns.RRSIG{
+		Hdr:         dns.RR_Header{Name: owner, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: ttl},
+		TypeCovered: rtype,
+		Algorithm:   key.Algorithm,
+		Labels:      uint8(dns.CountLabel(owner)),
+		OrigTtl:     ttl,
+		Expiration:  uint32(start.Add(dnssecSignatureValidity).Unix()),
+		Inception:   uint32(start.Add(-dnssecInceptionSkew).Unix()),
+		KeyTag:      key.KeyTag(),
+		SignerName:  s.zone,
+	}
+	if err := rrsig.Sign(signer, rrset); err != nil {
+		log.Printf("[valon] dnssec: failed to sign %s/%s: %v", owner, dns.TypeToString[rtype], err)
+		return nil
+	}
+	dnssecSignSeconds.Observe(time.Since(start).Seconds())
+
+	s.cache.put(cacheKey, rrsig)
+	return rrsig
+}
+
+// sigCache is a bounded cache of RRSIGs keyed by a hash of the RRset they
+// cover, so the many short-TTL A/SRV replies this plugin returns don't each
+// pay a fresh signing cost - only the much longer RRSIG validity window
+// (dnssecSignatureValidity) does.
+type sigCache struct {
+	mu    sync.Mutex
+	byKey map[string]*dns.RRSIG
+	order []string
+}
+
+func newSigCache() *sigCache {
+	return &sigCache{byKey: make(map[string]*dns.RRSIG)}
+}
+
+func (c *sigCache) get(key string) *dns.RRSIG {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.byKey[key]
+}
+
+func (c *sigCache) put(key string, sig *dns.RRSIG) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.byKey[key]; exists {
+		// Replacing an expired signature; key keeps its place in order.
+		c.byKey[key] = sig
+		return
+	}
+	c.byKey[key] = sig
+	c.order = append(c.order, key)
+	if len(c.order) > maxSigCacheEntries {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.byKey, oldest)
+	}
+}
+
+// sigCacheKey hashes (rrtype, owner, ttl, canonicalized rdata) into a cache
+// key. Rdata is approximated by each RR's presentation-format String(),
+// sorted so that RRset member order doesn't affect the key.
+func sigCacheKey(rtype uint16, owner string, ttl uint32, rrset []dns.RR) string {
+	rdata := make([]string, len(rrset))
+	for i, rr := range rrset {
+		rdata[i] = rr.String()
+	}
+	sort.Strings(rdata)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d|%s|%d", rtype, owner, ttl)
+	for _, s := range rdata {
+		h.Write([]byte{0})
+		h.Write([]byte(s))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signSection signs every RRset in rrs whose owner is under the signer's
+// zone, appending the RRSIGs after the records they cover. OPT and
+// already-present RRSIG records are left alone.
+func (v *Valon) signSection(rrs []dns.RR) []dns.RR {
+	if v.signer == nil || len(rrs) == 0 {
+		return rrs
+	}
+
+	type rrsetKey struct {
+		owner string
+		rtype uint16
+	}
+	type rrset struct {
+		ttl uint32
+		rrs []dns.RR
+	}
+
+	order := make([]rrsetKey, 0, len(rrs))
+	sets := make(map[rrsetKey]*rrset, len(rrs))
+
+	for _, rr := range rrs {
+		h := rr.Header()
+		if h.Rrtype == dns.TypeRRSIG || h.Rrtype == dns.TypeOPT {
+			continue
+		}
+		if !dns.IsSubDomain(v.signer.zone, h.Name) {
+			continue
+		}
+
+		k := rrsetKey{owner: h.Name, rtype: h.Rrtype}
+		set := sets[k]
+		if set == nil {
+			set = &rrset{ttl: h.Ttl}
+			sets[k] = set
+			order = append(order, k)
+		}
+		set.rrs = append(set.rrs, rr)
+	}
+
+	out := append([]dns.RR{}, rrs...)
+	for _, k := range order {
+		if sig := v.signer.sign(sets[k].rrs, sets[k].ttl); sig != nil {
+			out = append(out, sig)
+		}
+	}
+	return out
+}
+
+// signReply signs every eligible RRset in m's Answer, Ns, and Extra
+// sections, and - for an NXDOMAIN reply or a NODATA reply (NOERROR with an
+// empty Answer, e.g. an AAAA query for a v4-only peer, or a nated. query
+// for a peer with no NAT endpoint yet) - synthesizes the NSEC denial of
+// existence first so it gets signed along with everything else. It is a
+// no-op unless DNSSEC is configured and the query requested it via the DO
+// bit (RFC 4035 §3.2.1): plain resolvers shouldn't be handed signatures and
+// synthetic NSEC records they never asked for and can't validate.
+func (v *Valon) signReply(m *dns.Msg, r *dns.Msg) {
+	if v.signer == nil {
+		return
+	}
+	opt := r.IsEdns0()
+	if opt == nil || !opt.Do() {
+		return
+	}
+
+	if m.Rcode == dns.RcodeNameError || (m.Rcode == dns.RcodeSuccess && len(m.Answer) == 0) {
+		v.signer.synthesizeDenial(m, r)
+	}
+
+	m.Answer = v.signSection(m.Answer)
+	m.Ns = v.signSection(m.Ns)
+	m.Extra = v.signSection(m.Extra)
+}
+
+// synthesizeDenial adds a single synthetic NSEC record to m.Ns proving
+// whatever m.Rcode actually claims - neither "white lie" shape reflects a
+// real RFC 4035 NSEC chain (every name in this zone is derived
+// algorithmically from a WireGuard pubkey rather than drawn from an
+// enumerable, sorted zone file, so there is no real adjacent owner name to
+// walk to), but each is built to not contradict its own rcode:
+//
+//   - NXDOMAIN (m.Rcode == dns.RcodeNameError): qname does not exist at all,
+//     so the NSEC must *cover* it - Hdr.Name sorting strictly before qname
+//     and NextDomain sorting strictly after, with qname itself never an
+//     owner name anywhere in the reply. See precedingSibling/
+//     followingSibling.
+//   - NODATA (m.Rcode == dns.RcodeSuccess with an empty m.Answer): qname
+//     exists but lacks the queried type, so the NSEC instead *matches* it -
+//     Hdr.Name == qname, with TypeBitMap omitting the queried RRtype (it
+//     only ever asserts RRSIG/NSEC, which doesn't claim any real type
+//     exists at the name and is enough for a validator to conclude the
+//     queried type isn't among them).
+//
+// The technique mirrors the "white lie"/minimally-covering NSEC synthesis
+// used by other online signers for algorithmically-generated zones.
+func (s *dnssecSigner) synthesizeDenial(m *dns.Msg, r *dns.Msg) {
+	if len(r.Question) == 0 {
+		return
+	}
+	qname := r.Question[0].Name
+
+	if m.Rcode == dns.RcodeNameError {
+		nsec := &dns.NSEC{
+			Hdr:        dns.RR_Header{Name: precedingSibling(qname), Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 3600},
+			NextDomain: followingSibling(qname),
+			TypeBitMap: []uint16{dns.TypeRRSIG, dns.TypeNSEC},
+		}
+		m.Ns = append(m.Ns, nsec)
+		return
+	}
+
+	nsec := &dns.NSEC{
+		Hdr:        dns.RR_Header{Name: qname, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 3600},
+		NextDomain: "\\000." + qname,
+		TypeBitMap: []uint16{dns.TypeRRSIG, dns.TypeNSEC},
+	}
+	m.Ns = append(m.Ns, nsec)
+}
+
+// precedingSibling and followingSibling return a name that differs from
+// qname only in the last byte of its leftmost label (decremented or
+// incremented by one), so the result is qname's immediate neighbor in
+// DNSSEC canonical ordering (RFC 4034 §6.1): names sharing every label but
+// the leftmost compare by that label alone. Every label this zone hands out
+// (Base32/z-base-32 pubkey encodings, SHA-256-derived short IDs) is built
+// from a restricted lowercase-alphanumeric alphabet, so adjusting its last
+// byte by one never under/overflows a byte.
+func precedingSibling(qname string) string {
+	return adjacentSibling(qname, -1)
+}
+
+func followingSibling(qname string) string {
+	return adjacentSibling(qname, 1)
+}
+
+func adjacentSibling(qname string, delta int) string {
+	b := []byte(qname)
+	end := strings.IndexByte(qname, '.')
+	if end <= 0 {
+		return qname
+	}
+	b[end-1] = byte(int(b[end-1]) + delta)
+	return string(b)
+}
@@ -0,0 +1,76 @@
+package valon
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSynthesizeDenialNXDOMAINCovers(t *testing.T) {
+	qname := "4nnav7vcrtjwvtq6i6g6apw6oa.valon.internal."
+
+	r := new(dns.Msg)
+	r.SetQuestion(qname, dns.TypeA)
+
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeNameError)
+
+	s := &dnssecSigner{}
+	s.synthesizeDenial(m, r)
+
+	if len(m.Ns) != 1 {
+		t.Fatalf("m.Ns = %d records, want 1", len(m.Ns))
+	}
+	nsec, ok := m.Ns[0].(*dns.NSEC)
+	if !ok {
+		t.Fatalf("m.Ns[0] type = %T, want *dns.NSEC", m.Ns[0])
+	}
+
+	// An NXDOMAIN NSEC must cover qname without ever using it as an owner
+	// name anywhere in the reply - an NSEC owned by qname would assert
+	// qname exists, directly contradicting RcodeNameError.
+	if nsec.Hdr.Name == qname || nsec.NextDomain == qname {
+		t.Errorf("NXDOMAIN NSEC = {owner: %q, next: %q}, must not use qname %q as owner or next", nsec.Hdr.Name, nsec.NextDomain, qname)
+	}
+	// owner and NextDomain differ from qname only in the last byte of the
+	// leftmost label (see adjacentSibling), so plain byte comparison here
+	// reflects canonical DNSSEC ordering for this restricted alphabet.
+	if nsec.Hdr.Name >= qname {
+		t.Errorf("NSEC owner %q must sort before qname %q", nsec.Hdr.Name, qname)
+	}
+	if qname >= nsec.NextDomain {
+		t.Errorf("qname %q must sort before NSEC NextDomain %q", qname, nsec.NextDomain)
+	}
+}
+
+func TestSynthesizeDenialNODATAMatches(t *testing.T) {
+	qname := "4nnav7vcrtjwvtq6i6g6apw6oa.valon.internal."
+
+	r := new(dns.Msg)
+	r.SetQuestion(qname, dns.TypeAAAA)
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	s := &dnssecSigner{}
+	s.synthesizeDenial(m, r)
+
+	if len(m.Ns) != 1 {
+		t.Fatalf("m.Ns = %d records, want 1", len(m.Ns))
+	}
+	nsec, ok := m.Ns[0].(*dns.NSEC)
+	if !ok {
+		t.Fatalf("m.Ns[0] type = %T, want *dns.NSEC", m.Ns[0])
+	}
+
+	// A NODATA NSEC asserts qname exists but lacks the queried type, so
+	// it must be owned by qname itself and must not list the queried type.
+	if nsec.Hdr.Name != qname {
+		t.Errorf("NODATA NSEC owner = %q, want qname %q", nsec.Hdr.Name, qname)
+	}
+	for _, t2 := range nsec.TypeBitMap {
+		if t2 == dns.TypeAAAA {
+			t.Errorf("NODATA NSEC TypeBitMap = %v, must not include the queried type AAAA", nsec.TypeBitMap)
+		}
+	}
+}
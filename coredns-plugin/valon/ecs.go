@@ -0,0 +1,95 @@
+package valon
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// shortECSTTL is the TTL used on answers whose content depends on the
+// client's EDNS0 Client Subnet (RFC 7871), so that resolvers and
+// intermediate caches don't reuse an answer chosen for one subnet when
+// serving a client on another.
+const shortECSTTL = 5
+
+// clientSubnet holds the parsed EDNS0 Client Subnet option (RFC 7871) from
+// an incoming query.
+type clientSubnet struct {
+	IP     net.IP
+	Prefix int // SourceNetmask, in bits
+}
+
+// parseClientSubnet extracts the EDNS0 Client Subnet option from r's OPT RR,
+// if present.
+func parseClientSubnet(r *dns.Msg) *clientSubnet {
+	opt := r.IsEdns0()
+	if opt == nil {
+		return nil
+	}
+	for _, o := range opt.Option {
+		subnet, ok := o.(*dns.EDNS0_SUBNET)
+		if !ok || subnet.Address == nil {
+			continue
+		}
+		return &clientSubnet{IP: subnet.Address, Prefix: int(subnet.SourceNetmask)}
+	}
+	return nil
+}
+
+// matchesIP reports whether ip falls inside cs's subnet, building the
+// comparison network from cs's own address and SourceNetmask.
+func (cs *clientSubnet) matchesIP(ip net.IP) bool {
+	if cs == nil || ip == nil {
+		return false
+	}
+	bits := 32
+	if cs.IP.To4() == nil {
+		bits = 128
+	}
+	if cs.Prefix <= 0 || cs.Prefix > bits {
+		return false
+	}
+
+	network := &net.IPNet{IP: cs.IP.Mask(net.CIDRMask(cs.Prefix, bits)), Mask: net.CIDRMask(cs.Prefix, bits)}
+	return network.Contains(ip)
+}
+
+// setEDNS0Subnet attaches an EDNS0 Client Subnet reply option to m, echoing
+// cs's family/address/SourceNetmask and setting SourceScope to scope - the
+// prefix length actually used to choose between answers, or 0 if the answer
+// would have been the same regardless of the client's subnet (RFC 7871
+// §11.1). When scope is non-zero, m's existing answers are also given a
+// short TTL (see shortECSTTL) so the subnet-dependent choice isn't cached
+// and replayed to a client on a different subnet.
+func setEDNS0Subnet(m *dns.Msg, cs *clientSubnet, scope uint8) {
+	if cs == nil {
+		return
+	}
+
+	family := uint16(1)
+	if cs.IP.To4() == nil {
+		family = 2
+	}
+
+	opt := m.IsEdns0()
+	if opt == nil {
+		opt = new(dns.OPT)
+		opt.Hdr.Name = "."
+		opt.Hdr.Rrtype = dns.TypeOPT
+		m.Extra = append(m.Extra, opt)
+	}
+
+	opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        family,
+		SourceNetmask: uint8(cs.Prefix),
+		SourceScope:   scope,
+		Address:       cs.IP,
+	})
+
+	if scope != 0 {
+		for _, rr := range m.Answer {
+			rr.Header().Ttl = shortECSTTL
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package valon
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+)
+
+// testResponseWriter is a minimal dns.ResponseWriter that just captures the
+// message passed to WriteMsg, for asserting on handler output in tests.
+type testResponseWriter struct {
+	msg *dns.Msg
+}
+
+func (w *testResponseWriter) LocalAddr() net.Addr         { return &net.UDPAddr{} }
+func (w *testResponseWriter) RemoteAddr() net.Addr        { return &net.UDPAddr{} }
+func (w *testResponseWriter) WriteMsg(m *dns.Msg) error   { w.msg = m; return nil }
+func (w *testResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *testResponseWriter) Close() error                { return nil }
+func (w *testResponseWriter) TsigStatus() error           { return nil }
+func (w *testResponseWriter) TsigTimersOnly(bool)         {}
+func (w *testResponseWriter) Hijack()                     {}
+
+// withClientSubnet attaches an EDNS0 Client Subnet option for ip/prefix to
+// r's OPT RR, creating one if r isn't already EDNS0-enabled.
+func withClientSubnet(r *dns.Msg, ip string, prefix uint8) {
+	r.SetEdns0(4096, false)
+	opt := r.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		Family:        1,
+		SourceNetmask: prefix,
+		Address:       net.ParseIP(ip),
+	})
+}
+
+func TestParseClientSubnet(t *testing.T) {
+	r := new(dns.Msg)
+	r.SetQuestion("example.valon.internal.", dns.TypeA)
+	if cs := parseClientSubnet(r); cs != nil {
+		t.Fatalf("parseClientSubnet with no EDNS0 = %+v, want nil", cs)
+	}
+
+	withClientSubnet(r, "192.168.1.200", 24)
+	cs := parseClientSubnet(r)
+	if cs == nil {
+		t.Fatal("parseClientSubnet = nil, want a clientSubnet")
+	}
+	if cs.Prefix != 24 || !cs.IP.Equal(net.ParseIP("192.168.1.200")) {
+		t.Errorf("parseClientSubnet = %+v, want {192.168.1.200 24}", cs)
+	}
+}
+
+func TestClientSubnetMatchesIP(t *testing.T) {
+	cs := &clientSubnet{IP: net.ParseIP("192.168.1.200"), Prefix: 24}
+
+	if !cs.matchesIP(net.ParseIP("192.168.1.50")) {
+		t.Error("expected 192.168.1.50 to match 192.168.1.200/24")
+	}
+	if cs.matchesIP(net.ParseIP("10.0.0.5")) {
+		t.Error("expected 10.0.0.5 not to match 192.168.1.200/24")
+	}
+	if (*clientSubnet)(nil).matchesIP(net.ParseIP("192.168.1.50")) {
+		t.Error("expected nil clientSubnet to never match")
+	}
+}
+
+// setupPeer registers a single peer with both a LAN and a NAT endpoint and
+// returns its DNS label.
+func setupPeer(t *testing.T, v *Valon) string {
+	t.Helper()
+	pubkey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	label, err := pubkeyToDnsLabel(pubkey)
+	if err != nil {
+		t.Fatalf("pubkeyToDnsLabel: %v", err)
+	}
+	v.cache.Set(pubkey, &PeerInfo{
+		PubKey:      pubkey,
+		WgIP:        "100.64.0.5",
+		LANEndpoint: "192.168.1.50:51820",
+		NATEndpoint: "203.0.113.9:51820",
+	})
+	return label
+}
+
+func TestHandleAClientSubnetOnLAN(t *testing.T) {
+	v := &Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	label := setupPeer(t, v)
+
+	r := new(dns.Msg)
+	r.SetQuestion(label+".valon.internal.", dns.TypeA)
+	withClientSubnet(r, "192.168.1.200", 24)
+
+	w := &testResponseWriter{}
+	state := request.Request{W: w, Req: r}
+	if rc, err := v.handleA(context.Background(), w, r, state); err != nil || rc != dns.RcodeSuccess {
+		t.Fatalf("handleA() = (%d, %v)", rc, err)
+	}
+
+	if len(w.msg.Answer) != 1 {
+		t.Fatalf("answers = %d, want 1", len(w.msg.Answer))
+	}
+	a, ok := w.msg.Answer[0].(*dns.A)
+	if !ok {
+		t.Fatalf("answer type = %T, want *dns.A", w.msg.Answer[0])
+	}
+	if !a.A.Equal(net.ParseIP("192.168.1.50")) {
+		t.Errorf("A = %s, want LAN IP 192.168.1.50", a.A)
+	}
+	if a.Hdr.Ttl != shortECSTTL {
+		t.Errorf("TTL = %d, want %d (subnet-dependent answer)", a.Hdr.Ttl, shortECSTTL)
+	}
+
+	opt := w.msg.IsEdns0()
+	if opt == nil {
+		t.Fatal("reply has no OPT RR")
+	}
+	sub := findSubnetOption(t, opt)
+	if sub.SourceScope != 24 {
+		t.Errorf("SourceScope = %d, want 24", sub.SourceScope)
+	}
+}
+
+func TestHandleAClientSubnetOffLAN(t *testing.T) {
+	v := &Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	label := setupPeer(t, v)
+
+	r := new(dns.Msg)
+	r.SetQuestion(label+".valon.internal.", dns.TypeA)
+	withClientSubnet(r, "203.0.113.200", 24)
+
+	w := &testResponseWriter{}
+	state := request.Request{W: w, Req: r}
+	if rc, err := v.handleA(context.Background(), w, r, state); err != nil || rc != dns.RcodeSuccess {
+		t.Fatalf("handleA() = (%d, %v)", rc, err)
+	}
+
+	a := w.msg.Answer[0].(*dns.A)
+	if !a.A.Equal(net.ParseIP("100.64.0.5")) {
+		t.Errorf("A = %s, want WgIP 100.64.0.5", a.A)
+	}
+	if a.Hdr.Ttl != 30 {
+		t.Errorf("TTL = %d, want 30 (subnet-independent answer)", a.Hdr.Ttl)
+	}
+
+	// The client's subnet didn't match the peer's LAN, so the answer fell
+	// through to the WgIP - the same answer every client gets. SourceScope
+	// must be 0 (RFC 7871 §7.3.1) so resolvers don't cache it per-subnet.
+	opt := w.msg.IsEdns0()
+	if opt == nil {
+		t.Fatal("reply has no OPT RR")
+	}
+	sub := findSubnetOption(t, opt)
+	if sub.SourceScope != 0 {
+		t.Errorf("SourceScope = %d, want 0 (answer not steered by subnet)", sub.SourceScope)
+	}
+}
+
+func TestHandleANoClientSubnet(t *testing.T) {
+	v := &Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	label := setupPeer(t, v)
+
+	r := new(dns.Msg)
+	r.SetQuestion(label+".valon.internal.", dns.TypeA)
+
+	w := &testResponseWriter{}
+	state := request.Request{W: w, Req: r}
+	if rc, err := v.handleA(context.Background(), w, r, state); err != nil || rc != dns.RcodeSuccess {
+		t.Fatalf("handleA() = (%d, %v)", rc, err)
+	}
+
+	a := w.msg.Answer[0].(*dns.A)
+	if !a.A.Equal(net.ParseIP("100.64.0.5")) {
+		t.Errorf("A = %s, want WgIP 100.64.0.5 (no ECS to steer by)", a.A)
+	}
+	if a.Hdr.Ttl != 30 {
+		t.Errorf("TTL = %d, want 30 (no ECS option in query)", a.Hdr.Ttl)
+	}
+	if w.msg.IsEdns0() != nil {
+		t.Error("reply should carry no OPT RR when the query had none")
+	}
+}
+
+func TestHandleSRVClientSubnetPrefersNATOffLAN(t *testing.T) {
+	v := &Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	label := setupPeer(t, v)
+
+	r := new(dns.Msg)
+	r.SetQuestion("_wireguard._udp."+label+".valon.internal.", dns.TypeSRV)
+	withClientSubnet(r, "203.0.113.200", 24)
+
+	w := &testResponseWriter{}
+	state := request.Request{W: w, Req: r}
+	if rc, err := v.handleSRV(context.Background(), w, r, state); err != nil || rc != dns.RcodeSuccess {
+		t.Fatalf("handleSRV() = (%d, %v)", rc, err)
+	}
+
+	var lanPriority, natPriority uint16
+	for _, rr := range w.msg.Answer {
+		srv, ok := rr.(*dns.SRV)
+		if !ok {
+			continue
+		}
+		switch {
+		case len(srv.Target) > 4 && srv.Target[:4] == "lan.":
+			lanPriority = srv.Priority
+		default:
+			natPriority = srv.Priority
+		}
+	}
+
+	if natPriority >= lanPriority {
+		t.Errorf("NAT priority %d should outrank (be lower than) LAN priority %d when the client is off-LAN", natPriority, lanPriority)
+	}
+}
+
+func findSubnetOption(t *testing.T, opt *dns.OPT) *dns.EDNS0_SUBNET {
+	t.Helper()
+	for _, o := range opt.Option {
+		if sub, ok := o.(*dns.EDNS0_SUBNET); ok {
+			return sub
+		}
+	}
+	t.Fatal("no EDNS0_SUBNET option in reply OPT RR")
+	return nil
+}
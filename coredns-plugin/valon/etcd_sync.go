@@ -2,17 +2,20 @@ package valon
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"time"
-
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
-// startEtcdSync starts the etcd synchronization loop.
-// It periodically syncs dirty peers from memory cache to etcd.
+// startEtcdSync starts the periodic store synchronization loop.
+//
+// Propagation is primarily driven by startPeerWatch now; this loop is kept
+// running as a reconciliation safety net that periodically pushes any
+// locally-dirty peer (e.g. a NAT endpoint this node itself just observed)
+// to v.store in case a watch event was ever missed. Despite the name, it
+// now writes through the PeerStore abstraction rather than talking to etcd
+// directly, so it works the same way against any backend.
 func (v *Valon) startEtcdSync() {
-	log.Printf("[valon] Starting etcd sync (interval: %v)", v.EtcdSyncInterval)
+	log.Printf("[valon] Starting peer store sync (interval: %v)", v.EtcdSyncInterval)
 
 	ticker := time.NewTicker(v.EtcdSyncInterval)
 	defer ticker.Stop()
@@ -20,16 +23,16 @@ func (v *Valon) startEtcdSync() {
 	for {
 		select {
 		case <-ticker.C:
-			v.syncToEtcd()
+			v.syncToStore()
 		case <-v.stopCh:
-			log.Printf("[valon] etcd sync stopped")
+			log.Printf("[valon] peer store sync stopped")
 			return
 		}
 	}
 }
 
-// syncToEtcd synchronizes dirty peers to etcd.
-func (v *Valon) syncToEtcd() {
+// syncToStore writes every locally-dirty peer to v.store.
+func (v *Valon) syncToStore() {
 	peers := v.cache.GetAll()
 	dirtyCount := 0
 
@@ -38,8 +41,12 @@ func (v *Valon) syncToEtcd() {
 			continue
 		}
 
-		if err := v.writePeerToEtcd(pubkey, peerInfo); err != nil {
-			log.Printf("[valon] Failed to sync peer %s to etcd: %v", pubkey, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := v.store.Put(ctx, peerInfo)
+		cancel()
+		if err != nil {
+			log.Printf("[valon] Failed to sync peer %s to store: %v", pubkey, err)
+			etcdSyncErrors.Inc()
 			continue
 		}
 
@@ -52,43 +59,17 @@ func (v *Valon) syncToEtcd() {
 	}
 
 	if dirtyCount > 0 {
-		log.Printf("[valon] Synced %d dirty peers to etcd", dirtyCount)
+		log.Printf("[valon] Synced %d dirty peers to store", dirtyCount)
 	}
 }
 
-// writePeerToEtcd writes a single peer's information to etcd.
-func (v *Valon) writePeerToEtcd(pubkey string, peerInfo *PeerInfo) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Prepare key-value pairs
-	ops := []clientv3.Op{}
-
-	// Write WireGuard IP
-	if peerInfo.WgIP != "" {
-		key := fmt.Sprintf("/valon/peers/%s/wg_ip", pubkey)
-		ops = append(ops, clientv3.OpPut(key, peerInfo.WgIP))
+// bestNATEndpoint picks the endpoint to publish as the peer's canonical
+// "nated" address, preferring the self-reported STUN-reflexive endpoint
+// (more likely to work before any peer has handshaked with us) over the
+// endpoint merely observed from wg show.
+func bestNATEndpoint(peerInfo *PeerInfo) string {
+	if peerInfo.StunEndpoint != "" {
+		return peerInfo.StunEndpoint
 	}
-
-	// Write LAN endpoint (from DDNS API)
-	if peerInfo.LANEndpoint != "" {
-		key := fmt.Sprintf("/valon/peers/%s/endpoints/lan", pubkey)
-		ops = append(ops, clientv3.OpPut(key, peerInfo.LANEndpoint))
-	}
-
-	// Write NAT endpoint (from wg show observation)
-	if peerInfo.NATEndpoint != "" {
-		key := fmt.Sprintf("/valon/peers/%s/endpoints/nated", pubkey)
-		ops = append(ops, clientv3.OpPut(key, peerInfo.NATEndpoint))
-	}
-
-	// Execute transaction
-	if len(ops) > 0 {
-		_, err := v.etcdClient.Txn(ctx).Then(ops...).Commit()
-		if err != nil {
-			return fmt.Errorf("etcd transaction failed: %w", err)
-		}
-	}
-
-	return nil
+	return peerInfo.NATEndpoint
 }
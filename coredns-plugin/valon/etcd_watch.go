@@ -0,0 +1,159 @@
+package valon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/pabotesu/valon/coredns-plugin/valon/wgcfg"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// maxConsecutiveCompactions is the number of ErrCompacted responses in a row
+// at which an etcd-backed PeerStore's Watch gives up on watching and falls
+// back to periodic polling; each earlier one in the run triggers a resync
+// and a resumed watch. It is
+// declared here (rather than in store_etcd.go) because it originated as,
+// and remains, a property of how eagerly the plugin as a whole tolerates a
+// lagging watch before preferring staleness over churn.
+const maxConsecutiveCompactions = 2
+
+// startPeerWatch consumes v.store's change stream and applies each event to
+// the in-memory cache as it arrives, so peers learn about new endpoints
+// immediately instead of waiting for the next periodic sync. It works
+// against any PeerStore implementation - the backend-specific resume/
+// compaction/poll-fallback behavior lives inside each store's Watch method
+// (see EtcdStore.Watch).
+func (v *Valon) startPeerWatch() {
+	log.Printf("[valon] Starting peer store watch")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-v.stopCh
+		cancel()
+	}()
+
+	events, err := v.store.Watch(ctx)
+	if err != nil {
+		log.Printf("[valon] Failed to start peer store watch: %v", err)
+		return
+	}
+
+	for ev := range events {
+		v.applyPeerEvent(ev)
+	}
+	log.Printf("[valon] Peer store watch stopped")
+}
+
+// applyPeerEvent updates the in-memory cache in response to a single
+// PeerEvent, and reconfigures the WireGuard device if a currently
+// programmed peer's endpoint changed.
+func (v *Valon) applyPeerEvent(ev PeerEvent) {
+	if ev.Type == PeerEventDelete {
+		v.cache.Delete(ev.Peer.PubKey)
+		log.Printf("[valon] peer store watch: peer %s removed", ev.Peer.PubKey[:min(len(ev.Peer.PubKey), 16)])
+		return
+	}
+
+	pubkey := ev.Peer.PubKey
+	existing := v.cache.Get(pubkey)
+
+	endpointChanged := existing == nil ||
+		existing.LANEndpoint != ev.Peer.LANEndpoint ||
+		existing.NATEndpoint != ev.Peer.NATEndpoint ||
+		existing.Endpoint != ev.Peer.Endpoint
+
+	if existing == nil {
+		v.cache.Set(pubkey, ev.Peer)
+	} else {
+		v.cache.Update(pubkey, func(p *PeerInfo) {
+			incoming := ev.Peer
+			p.WgIP = incoming.WgIP
+			p.WgIPv6 = incoming.WgIPv6
+			p.Alias = incoming.Alias
+			p.LANEndpoint = incoming.LANEndpoint
+			p.LANEndpointV6 = incoming.LANEndpointV6
+			p.NATEndpoint = incoming.NATEndpoint
+			p.NATEndpointV6 = incoming.NATEndpointV6
+			p.StunEndpoint = incoming.StunEndpoint
+			p.NATType = incoming.NATType
+			p.Role = incoming.Role
+			p.RelayedVia = incoming.RelayedVia
+			p.Endpoint = incoming.Endpoint
+			p.IsStatic = incoming.IsStatic
+			if incoming.etcdRevision > p.etcdRevision {
+				p.etcdRevision = incoming.etcdRevision
+			}
+		})
+	}
+
+	if endpointChanged {
+		v.reconfigurePeerEndpoint(pubkey)
+	}
+}
+
+// reconfigurePeerEndpoint pushes an updated Endpoint to the kernel WireGuard
+// device for a peer that is currently programmed, so a roamed peer is
+// reachable again without waiting for the next handshake retry.
+func (v *Valon) reconfigurePeerEndpoint(pubkey string) {
+	peer := v.cache.Get(pubkey)
+	if peer == nil {
+		return
+	}
+	if v.LazyPeers && !peer.Installed {
+		// Not materialized yet; the new endpoint will be used whenever it is.
+		return
+	}
+
+	endpoint := peer.NATEndpoint
+	if peer.LANEndpoint != "" {
+		endpoint = peer.LANEndpoint
+	}
+	if peer.IsStatic {
+		// A static peer's endpoint is pinned by the operator, not learned
+		// via LAN/NAT discovery.
+		endpoint = peer.Endpoint
+	}
+	if endpoint == "" {
+		return
+	}
+
+	if err := v.updatePeerEndpoint(pubkey, endpoint); err != nil {
+		log.Printf("[valon] Failed to push updated endpoint for peer %s: %v", pubkey[:min(len(pubkey), 16)], err)
+		return
+	}
+	log.Printf("[valon] Pushed updated endpoint for peer %s: %s", pubkey[:min(len(pubkey), 16)], endpoint)
+}
+
+// updatePeerEndpoint reconfigures a single peer's Endpoint on the kernel
+// WireGuard device via wgctrl, without touching its AllowedIPs.
+func (v *Valon) updatePeerEndpoint(pubkey, endpoint string) error {
+	nodeKey, err := wgcfg.ParseNodePublicBase64(pubkey)
+	if err != nil {
+		return fmt.Errorf("invalid pubkey: %w", err)
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("failed to create wgctrl client: %w", err)
+	}
+	defer client.Close()
+
+	cfg := wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:         nodeKey.Raw(),
+			Endpoint:          udpAddr,
+			UpdateOnly:        true,
+			ReplaceAllowedIPs: false,
+		}},
+	}
+
+	return client.ConfigureDevice(v.WgInterface, cfg)
+}
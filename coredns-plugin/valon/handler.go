@@ -16,6 +16,10 @@ import (
 
 // ServeDNS implements the plugin.Handler interface.
 func (v Valon) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	if r.Opcode == dns.OpcodeUpdate {
+		return v.handleUpdate(w, r)
+	}
+
 	state := request.Request{W: w, Req: r}
 
 	// Check if the query is for our zone
@@ -30,21 +34,56 @@ func (v Valon) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 	switch state.QType() {
 	case dns.TypeA:
 		return v.handleA(ctx, w, r, state)
+	case dns.TypeAAAA:
+		return v.handleAAAA(ctx, w, r, state)
 	case dns.TypeSRV:
+		if label, svc, ok := splitDNSSDInstance(state.Name(), v.Zone); ok {
+			return v.handleDNSSDInstanceSRV(w, r, state, label, svc)
+		}
 		return v.handleSRV(ctx, w, r, state)
+	case dns.TypePTR:
+		if svc, ok := dnsSDBrowseService(state.Name(), v.Zone); ok {
+			return v.handleDNSSDBrowse(w, r, state, svc)
+		}
+		return v.nxdomain(w, r)
+	case dns.TypeTXT:
+		if label, svc, ok := splitDNSSDInstance(state.Name(), v.Zone); ok {
+			return v.handleDNSSDInstanceTXT(w, r, state, label, svc)
+		}
+		return v.nxdomain(w, r)
+	case dns.TypeDNSKEY:
+		if v.signer != nil && state.Name() == v.Zone {
+			return v.handleDNSKEY(w, r, state)
+		}
+		return v.nxdomain(w, r)
+	case dns.TypeCDS, dns.TypeCDNSKEY:
+		if v.signer != nil && v.signer.ksk != nil && state.Name() == v.Zone {
+			return v.handleCDS(w, r, state)
+		}
+		return v.nxdomain(w, r)
 	default:
 		// Unsupported query type, return NXDOMAIN
 		return v.nxdomain(w, r)
 	}
 }
 
-// handleA handles A record queries.
-// Supports both direct pubkey queries and CNAME aliases.
-func (v Valon) handleA(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request) (int, error) {
-	m := new(dns.Msg)
-	m.SetReply(r)
-	m.Authoritative = true
+// addressQuery is the result of resolveAddressQuery: a name under the zone
+// that resolved to a peer, shared by handleA and handleAAAA, which then
+// differ only in which address family's fields they read off peer.
+type addressQuery struct {
+	pubkey       string
+	dnsLabel     string
+	peer         *PeerInfo
+	isEndpoint   bool
+	endpointType string // "LAN" or "NAT", meaningful only if isEndpoint
+}
 
+// resolveAddressQuery parses state's name the way handleA and handleAAAA
+// both need to (bare/lan./nated./alias), and looks up the resulting peer.
+// If handled is true, the caller has already written a response - a CNAME
+// for a resolved alias, or NXDOMAIN for a label or pubkey that doesn't
+// resolve to anything - and should return (rcode, err) immediately.
+func (v Valon) resolveAddressQuery(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request) (q *addressQuery, handled bool, rcode int, err error) {
 	// Extract label from query name
 	// Format: <base32-label>.valon.internal. or lan.<base32-label>.valon.internal. or nated.<base32-label>.valon.internal.
 	// Or: <alias>.valon.internal. (CNAME to base32 label)
@@ -69,28 +108,64 @@ func (v Valon) handleA(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, st
 	}
 
 	// Try to convert DNS label (base32) to WireGuard pubkey (base64)
-	pubkey, err := dnsLabelToPubkey(dnsLabel)
-	if err != nil {
+	pubkey, decodeErr := dnsLabelToPubkey(dnsLabel)
+	if decodeErr != nil {
 		// Not a valid base32 label, try alias lookup
 		if !isEndpoint {
 			if targetLabel := v.lookupAlias(ctx, dnsLabel); targetLabel != "" {
 				log.Printf("[valon] Alias lookup: %s -> %s", dnsLabel, targetLabel)
-				return v.returnCNAME(ctx, w, r, state, targetLabel)
+				rcode, err = v.returnCNAME(ctx, w, r, state, targetLabel)
+				return nil, true, rcode, err
 			}
 		}
-		log.Printf("[valon] Invalid DNS label format: %s (%v)", dnsLabel, err)
-		return v.nxdomain(w, r)
+		log.Printf("[valon] Invalid DNS label format: %s (%v)", dnsLabel, decodeErr)
+		rcode, err = v.nxdomain(w, r)
+		return nil, true, rcode, err
 	}
 
-	log.Printf("[valon] A query for: %s (label: %s, pubkey: %s)", state.Name(), dnsLabel, pubkey)
-
 	// Query cache
 	peerInfo := v.cache.Get(pubkey)
 	if peerInfo == nil {
 		log.Printf("[valon] No data found in cache for pubkey: %s", pubkey)
-		return v.nxdomain(w, r)
+		rcode, err = v.nxdomain(w, r)
+		return nil, true, rcode, err
+	}
+
+	return &addressQuery{
+		pubkey:       pubkey,
+		dnsLabel:     dnsLabel,
+		peer:         peerInfo,
+		isEndpoint:   isEndpoint,
+		endpointType: endpointType,
+	}, false, 0, nil
+}
+
+// handleA handles A record queries.
+// Supports both direct pubkey queries and CNAME aliases.
+func (v Valon) handleA(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request) (int, error) {
+	q, handled, rcode, err := v.resolveAddressQuery(ctx, w, r, state)
+	if handled {
+		return rcode, err
+	}
+	pubkey, peerInfo, isEndpoint, endpointType := q.pubkey, q.peer, q.isEndpoint, q.endpointType
+
+	log.Printf("[valon] A query for: %s (label: %s, pubkey: %s)", state.Name(), q.dnsLabel, pubkey)
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	// A successful lookup means something intends to talk to this peer -
+	// treat it as the outbound-packet signal for lazy materialization.
+	if v.LazyPeers && !isEndpoint {
+		if err := v.materializePeer(pubkey); err != nil {
+			log.Printf("[valon] Failed to materialize peer %s: %v", pubkey, err)
+		}
 	}
 
+	cs := parseClientSubnet(r)
+	var ecsScope uint8
+
 	var value string
 	if isEndpoint {
 		if endpointType == "LAN" {
@@ -99,14 +174,35 @@ func (v Valon) handleA(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, st
 			value = peerInfo.NATEndpoint
 		}
 		if value == "" {
+			// The peer exists, it just has no IPv4 endpoint of this kind yet.
 			log.Printf("[valon] %s endpoint not available for pubkey: %s", endpointType, pubkey)
-			return v.nxdomain(w, r)
+			return v.nodata(w, r)
 		}
 	} else {
-		value = peerInfo.WgIP
+		// A bare <label>.valon.internal query is subnet-aware: if the
+		// client's advertised subnet overlaps the peer's LAN endpoint,
+		// steer the answer to the LAN IP instead of the WireGuard overlay
+		// IP, since it's reachable more directly from that network.
+		if peerInfo.LANEndpoint != "" && cs != nil {
+			if lanHost, _, err := net.SplitHostPort(peerInfo.LANEndpoint); err == nil {
+				if lanIP := net.ParseIP(lanHost); lanIP != nil && cs.matchesIP(lanIP) {
+					value = lanHost
+					// Only steering to the LAN IP makes the answer subnet-
+					// dependent; falling through to the WgIP below is the
+					// same answer every client gets, so SourceScope must
+					// stay 0 (RFC 7871 §7.3.1) to tell the resolver not to
+					// cache it per-subnet.
+					ecsScope = uint8(cs.Prefix)
+				}
+			}
+		}
 		if value == "" {
+			value = peerInfo.WgIP
+		}
+		if value == "" {
+			// The peer exists, it just has no WireGuard IPv4 address.
 			log.Printf("[valon] WireGuard IP not available for pubkey: %s", pubkey)
-			return v.nxdomain(w, r)
+			return v.nodata(w, r)
 		}
 	}
 
@@ -124,8 +220,8 @@ func (v Valon) handleA(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, st
 		ip = net.ParseIP(value)
 	}
 
-	if ip == nil {
-		log.Printf("[valon] Invalid IP address: %s", value)
+	if ip == nil || ip.To4() == nil {
+		log.Printf("[valon] Invalid IPv4 address: %s", value)
 		return v.nxdomain(w, r)
 	}
 
@@ -140,12 +236,108 @@ func (v Valon) handleA(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, st
 		A: ip.To4(),
 	}
 	m.Answer = append(m.Answer, rr)
+	setEDNS0Subnet(m, cs, ecsScope)
+	v.signReply(m, r)
 
 	log.Printf("[valon] Returning A record: %s -> %s", state.Name(), ip.String())
 	w.WriteMsg(m)
 	return dns.RcodeSuccess, nil
 }
 
+// handleAAAA handles AAAA record queries. It mirrors handleA exactly, but
+// reads the IPv6 counterpart of every field handleA reads (WgIPv6 instead
+// of WgIP, and so on) - see resolveAddressQuery for the label/alias/cache
+// lookup the two share.
+func (v Valon) handleAAAA(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request) (int, error) {
+	q, handled, rcode, err := v.resolveAddressQuery(ctx, w, r, state)
+	if handled {
+		return rcode, err
+	}
+	pubkey, peerInfo, isEndpoint, endpointType := q.pubkey, q.peer, q.isEndpoint, q.endpointType
+
+	log.Printf("[valon] AAAA query for: %s (label: %s, pubkey: %s)", state.Name(), q.dnsLabel, pubkey)
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if v.LazyPeers && !isEndpoint {
+		if err := v.materializePeer(pubkey); err != nil {
+			log.Printf("[valon] Failed to materialize peer %s: %v", pubkey, err)
+		}
+	}
+
+	cs := parseClientSubnet(r)
+	var ecsScope uint8
+
+	var value string
+	if isEndpoint {
+		if endpointType == "LAN" {
+			value = peerInfo.LANEndpointV6
+		} else {
+			value = peerInfo.NATEndpointV6
+		}
+		if value == "" {
+			// The peer exists, it just has no IPv6 endpoint of this kind yet.
+			log.Printf("[valon] %s IPv6 endpoint not available for pubkey: %s", endpointType, pubkey)
+			return v.nodata(w, r)
+		}
+	} else {
+		if peerInfo.LANEndpointV6 != "" && cs != nil {
+			if lanHost, _, err := net.SplitHostPort(peerInfo.LANEndpointV6); err == nil {
+				if lanIP := net.ParseIP(lanHost); lanIP != nil && cs.matchesIP(lanIP) {
+					value = lanHost
+					// See handleA's identical branch: only set SourceScope
+					// when the answer was actually steered to the LAN IP.
+					ecsScope = uint8(cs.Prefix)
+				}
+			}
+		}
+		if value == "" {
+			value = peerInfo.WgIPv6
+		}
+		if value == "" {
+			// The peer exists, it just has no WireGuard IPv6 address.
+			log.Printf("[valon] WireGuard IPv6 address not available for pubkey: %s", pubkey)
+			return v.nodata(w, r)
+		}
+	}
+
+	var ip net.IP
+	if isEndpoint {
+		host, _, err := net.SplitHostPort(value)
+		if err != nil {
+			log.Printf("[valon] Invalid %s IPv6 endpoint format: %s", endpointType, value)
+			return v.nxdomain(w, r)
+		}
+		ip = net.ParseIP(host)
+	} else {
+		ip = net.ParseIP(value)
+	}
+
+	if ip == nil || ip.To4() != nil {
+		log.Printf("[valon] Invalid IPv6 address: %s", value)
+		return v.nxdomain(w, r)
+	}
+
+	rr := &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   state.Name(),
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassINET,
+			Ttl:    30,
+		},
+		AAAA: ip,
+	}
+	m.Answer = append(m.Answer, rr)
+	setEDNS0Subnet(m, cs, ecsScope)
+	v.signReply(m, r)
+
+	log.Printf("[valon] Returning AAAA record: %s -> %s", state.Name(), ip.String())
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
 // handleSRV handles SRV record queries.
 // Queries etcd for endpoint information and returns SRV records.
 func (v Valon) handleSRV(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request) (int, error) {
@@ -181,6 +373,32 @@ func (v Valon) handleSRV(ctx context.Context, w dns.ResponseWriter, r *dns.Msg,
 		return v.nxdomain(w, r)
 	}
 
+	// An SRV lookup is also a sign of intent to connect to this peer, so it
+	// should materialize it the same way an A lookup does (see handleA).
+	if v.LazyPeers {
+		if err := v.materializePeer(pubkey); err != nil {
+			log.Printf("[valon] Failed to materialize peer %s: %v", pubkey, err)
+		}
+	}
+
+	// Steer which endpoint wins (lower SRV priority value = higher
+	// priority) using the client's EDNS0 Client Subnet, if present: when
+	// the client's subnet overlaps the peer's LAN endpoint, prefer LAN;
+	// when it doesn't (the client is evidently elsewhere), prefer NAT.
+	// With no ECS option at all, fall back to the original LAN-first
+	// default.
+	cs := parseClientSubnet(r)
+	var ecsScope uint8
+	lanPriority, natPriority := uint16(0), uint16(10)
+	if peerInfo.LANEndpoint != "" && peerInfo.NATEndpoint != "" && cs != nil {
+		ecsScope = uint8(cs.Prefix)
+		if lanHost, _, err := net.SplitHostPort(peerInfo.LANEndpoint); err == nil {
+			if lanIP := net.ParseIP(lanHost); lanIP != nil && !cs.matchesIP(lanIP) {
+				lanPriority, natPriority = 10, 0
+			}
+		}
+	}
+
 	// Process LAN endpoint (from DDNS API)
 	if peerInfo.LANEndpoint != "" {
 		endpoint := peerInfo.LANEndpoint
@@ -196,26 +414,17 @@ func (v Valon) handleSRV(ctx context.Context, w dns.ResponseWriter, r *dns.Msg,
 					Class:  dns.ClassINET,
 					Ttl:    30,
 				},
-				Priority: 0, // Higher priority
+				Priority: lanPriority,
 				Weight:   0,
 				Port:     uint16(port),
 				Target:   target,
 			}
 			m.Answer = append(m.Answer, srv)
 
-			// Add A record in Additional section
-			ip := net.ParseIP(host)
-			if ip != nil {
-				a := &dns.A{
-					Hdr: dns.RR_Header{
-						Name:   target,
-						Rrtype: dns.TypeA,
-						Class:  dns.ClassINET,
-						Ttl:    30,
-					},
-					A: ip.To4(),
-				}
-				m.Extra = append(m.Extra, a)
+			// Add glue (A or AAAA, depending on the endpoint's family) in
+			// the Additional section.
+			if ip := net.ParseIP(host); ip != nil {
+				m.Extra = append(m.Extra, glueRR(target, ip))
 			}
 			log.Printf("[valon] Added LAN SRV record: %s -> %s:%d", state.Name(), host, port)
 		}
@@ -236,26 +445,17 @@ func (v Valon) handleSRV(ctx context.Context, w dns.ResponseWriter, r *dns.Msg,
 					Class:  dns.ClassINET,
 					Ttl:    30,
 				},
-				Priority: 10, // Lower priority
+				Priority: natPriority,
 				Weight:   0,
 				Port:     uint16(port),
 				Target:   target,
 			}
 			m.Answer = append(m.Answer, srv)
 
-			// Add A record in Additional section
-			ip := net.ParseIP(host)
-			if ip != nil {
-				a := &dns.A{
-					Hdr: dns.RR_Header{
-						Name:   target,
-						Rrtype: dns.TypeA,
-						Class:  dns.ClassINET,
-						Ttl:    30,
-					},
-					A: ip.To4(),
-				}
-				m.Extra = append(m.Extra, a)
+			// Add glue (A or AAAA, depending on the endpoint's family) in
+			// the Additional section.
+			if ip := net.ParseIP(host); ip != nil {
+				m.Extra = append(m.Extra, glueRR(target, ip))
 			}
 			log.Printf("[valon] Added NAT SRV record: %s -> %s:%d", state.Name(), host, port)
 		}
@@ -267,6 +467,9 @@ func (v Valon) handleSRV(ctx context.Context, w dns.ResponseWriter, r *dns.Msg,
 		return v.nxdomain(w, r)
 	}
 
+	setEDNS0Subnet(m, cs, ecsScope)
+	v.signReply(m, r)
+
 	w.WriteMsg(m)
 	return dns.RcodeSuccess, nil
 }
@@ -276,13 +479,72 @@ func (v Valon) nxdomain(w dns.ResponseWriter, r *dns.Msg) (int, error) {
 	m := new(dns.Msg)
 	m.SetRcode(r, dns.RcodeNameError)
 	m.Authoritative = true
+	v.signReply(m, r) // synthesizes and signs the NSEC denial, see dnssec.go
 	w.WriteMsg(m)
 	return dns.RcodeNameError, nil
 }
 
+// nodata returns an empty-answer NOERROR response with a synthesized SOA
+// in the Authority section (RFC 2308 §2.2), for a name resolveAddressQuery
+// found a peer for - the name exists - but the requested RRtype has no
+// data (e.g. an AAAA query for a v4-only peer, or a nated. query for a
+// peer with no NAT endpoint yet). signReply adds and signs the NSEC
+// denial-of-type alongside the SOA when the query set the DO bit, see
+// synthesizeDenial in dnssec.go.
+func (v Valon) nodata(w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.Ns = append(m.Ns, v.synthesizedSOA())
+	v.signReply(m, r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// synthesizedSOA builds a minimal SOA record for the zone apex. Like
+// synthesizeDenial's NSEC (see dnssec.go), this is a "white lie" rather
+// than an authored record: every name in this zone is derived
+// algorithmically, there is no zone file to draw a real SOA from, and
+// nothing in this plugin currently serves AXFR/IXFR for Serial to matter
+// to a secondary, so it is fixed at 1.
+func (v Valon) synthesizedSOA() *dns.SOA {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: v.Zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+		Ns:      "ns." + v.Zone,
+		Mbox:    "hostmaster." + v.Zone,
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  604800,
+		Minttl:  60,
+	}
+}
+
+// glueRR returns the A or AAAA record for ip at name, whichever its family
+// calls for - handleSRV's LAN/NAT blocks share this instead of each
+// special-casing ip.To4() themselves.
+func glueRR(name string, ip net.IP) dns.RR {
+	if v4 := ip.To4(); v4 != nil {
+		return &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 30},
+			A:   v4,
+		}
+	}
+	return &dns.AAAA{
+		Hdr:  dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 30},
+		AAAA: ip,
+	}
+}
+
 // lookupAlias queries etcd for CNAME alias mapping.
 // Returns the target base32 label if found, empty string otherwise.
 func (v Valon) lookupAlias(ctx context.Context, alias string) string {
+	if v.etcdClient == nil {
+		// Alias resolution is an etcd-only secondary index; other backends
+		// simply have no aliases to resolve.
+		return ""
+	}
+
 	key := fmt.Sprintf("/valon/aliases/%s", alias)
 
 	ctxTimeout, cancel := context.WithTimeout(ctx, 1*time.Second)
@@ -301,8 +563,9 @@ func (v Valon) lookupAlias(ctx context.Context, alias string) string {
 	return strings.TrimSpace(string(resp.Kvs[0].Value))
 }
 
-// returnCNAME returns a CNAME record pointing to the target label,
-// along with the target's A record in the answer section.
+// returnCNAME returns a CNAME record pointing to the target label, along
+// with the target's address record in the answer section - an A for a
+// QTYPE A query, an AAAA for a QTYPE AAAA query.
 func (v Valon) returnCNAME(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request, targetLabel string) (int, error) {
 	m := new(dns.Msg)
 	m.SetReply(r)
@@ -321,36 +584,81 @@ func (v Valon) returnCNAME(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	}
 	m.Answer = append(m.Answer, cname)
 
-	// Resolve target and add A record
+	// Resolve target and add its address record
 	pubkey, err := dnsLabelToPubkey(targetLabel)
 	if err != nil {
 		log.Printf("[valon] Invalid target label in CNAME: %s (%v)", targetLabel, err)
+		v.signReply(m, r)
 		w.WriteMsg(m) // Return CNAME only
 		return dns.RcodeSuccess, nil
 	}
 
 	peerInfo := v.cache.Get(pubkey)
-	if peerInfo == nil || peerInfo.WgIP == "" {
+	if peerInfo == nil {
 		log.Printf("[valon] Target not found in cache for CNAME: %s", targetLabel)
+		v.signReply(m, r)
 		w.WriteMsg(m) // Return CNAME only
 		return dns.RcodeSuccess, nil
 	}
 
-	ip := net.ParseIP(peerInfo.WgIP)
-	if ip != nil {
-		a := &dns.A{
-			Hdr: dns.RR_Header{
-				Name:   targetFQDN,
-				Rrtype: dns.TypeA,
-				Class:  dns.ClassINET,
-				Ttl:    30,
-			},
-			A: ip.To4(),
+	wantV6 := state.QType() == dns.TypeAAAA
+	targetIP := peerInfo.WgIP
+	if wantV6 {
+		targetIP = peerInfo.WgIPv6
+	}
+	if targetIP == "" {
+		family := "IPv4"
+		if wantV6 {
+			family = "IPv6"
 		}
-		m.Answer = append(m.Answer, a)
+		log.Printf("[valon] Target has no %s address for CNAME: %s", family, targetLabel)
+		v.signReply(m, r)
+		w.WriteMsg(m) // Return CNAME only
+		return dns.RcodeSuccess, nil
+	}
+
+	if ip := net.ParseIP(targetIP); ip != nil && (ip.To4() != nil) != wantV6 {
+		m.Answer = append(m.Answer, glueRR(targetFQDN, ip))
+	}
+
+	v.signReply(m, r)
+	log.Printf("[valon] Returning CNAME: %s -> %s -> %s", state.Name(), targetFQDN, targetIP)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// handleDNSKEY answers a DNSKEY query at the zone apex with the configured
+// ZSK (and KSK, if any).
+func (v Valon) handleDNSKEY(w dns.ResponseWriter, r *dns.Msg, state request.Request) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	m.Answer = append(m.Answer, v.signer.zsk)
+	if v.signer.ksk != nil {
+		m.Answer = append(m.Answer, v.signer.ksk)
+	}
+
+	v.signReply(m, r)
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// handleCDS answers a CDS or CDNSKEY query at the zone apex (RFC 7344),
+// advertising the KSK for a parent zone to pick up. Only reachable when a
+// KSK is configured (see ServeDNS).
+func (v Valon) handleCDS(w dns.ResponseWriter, r *dns.Msg, state request.Request) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	if state.QType() == dns.TypeCDS {
+		m.Answer = append(m.Answer, v.signer.cds())
+	} else {
+		m.Answer = append(m.Answer, v.signer.cdnskey())
 	}
 
-	log.Printf("[valon] Returning CNAME: %s -> %s -> %s", state.Name(), targetFQDN, peerInfo.WgIP)
+	v.signReply(m, r)
 	w.WriteMsg(m)
 	return dns.RcodeSuccess, nil
 }
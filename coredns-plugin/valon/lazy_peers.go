@@ -0,0 +1,185 @@
+package valon
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// lazyPeerSweepInterval is how often the idle-eviction supervisor checks
+// installed peers against Valon.LazyIdleThreshold.
+const lazyPeerSweepInterval = 30 * time.Second
+
+// startLazyPeerSupervisor runs in the background when LazyPeers is enabled.
+// It evicts peers from the kernel WireGuard device once they have been idle
+// longer than LazyIdleThreshold, while keeping their etcd/cache entry intact
+// so they can be re-materialized on the next signal of activity.
+//
+// This mirrors Tailscale's wgengine lazy-peer approach: the "known peers"
+// set always lives in PeerCache (populated from etcd), but only a subset is
+// ever programmed into the kernel device at a given time.
+func (v *Valon) startLazyPeerSupervisor() {
+	log.Printf("[valon] Starting lazy peer supervisor (idle threshold: %v)", v.LazyIdleThreshold)
+
+	ticker := time.NewTicker(lazyPeerSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			v.evictIdlePeers()
+		case <-v.stopCh:
+			log.Printf("[valon] Lazy peer supervisor stopped")
+			return
+		}
+	}
+}
+
+// evictIdlePeers deprograms any installed peer that has had no handshake
+// and no observed DNS-triggered activity for longer than LazyIdleThreshold.
+func (v *Valon) evictIdlePeers() {
+	now := time.Now()
+
+	for pubkey, peer := range v.cache.GetAll() {
+		if !peer.Installed {
+			continue
+		}
+
+		lastActive := peer.LastActivity
+		if peer.LastHandshake.After(lastActive) {
+			lastActive = peer.LastHandshake
+		}
+
+		if lastActive.IsZero() || now.Sub(lastActive) < v.LazyIdleThreshold {
+			continue
+		}
+
+		if err := v.deprogramPeer(pubkey); err != nil {
+			log.Printf("[valon] Failed to deprogram idle peer %s: %v", pubkey[:min(len(pubkey), 16)], err)
+			continue
+		}
+		log.Printf("[valon] Deprogrammed idle peer %s (idle for %v)", pubkey[:min(len(pubkey), 16)], now.Sub(lastActive))
+	}
+}
+
+// materializePeer installs a known peer into the kernel WireGuard device.
+// It is triggered by an outbound DNS resolution for the peer (handleA /
+// handleSRV), and by pollWireGuard rediscovering a peer that's already
+// live in `wg show` output but wasn't yet in the cache (see the
+// loadPeerFromStore branch of processPeer in wg_monitor.go). For a peer that
+// is already installed it only refreshes LastActivity; a peer unknown to the
+// cache is an error.
+//
+// Known limitation: there is currently no inbound-handshake trigger for a
+// peer that evictIdlePeers has already deprogrammed. Once deprogrammed, a
+// peer is removed from the kernel device entirely, so pollWireGuard's
+// wgctrl.Device() call - which only ever reports peers the device already
+// has configured - cannot observe a remote peer's handshake attempts
+// against it. Detecting that would need a raw AF_PACKET/BPF listener on
+// the WireGuard interface to see handshake-initiation packets addressed to
+// an unconfigured peer, which this plugin does not implement; such a peer
+// can only be re-materialized by a local outbound DNS lookup for it. See
+// pollWireGuard's doc comment in wg_monitor.go.
+func (v *Valon) materializePeer(pubkey string) error {
+	peer := v.cache.Get(pubkey)
+	if peer == nil {
+		return fmt.Errorf("peer %s not found in cache", pubkey)
+	}
+	if peer.Installed {
+		v.cache.Update(pubkey, func(p *PeerInfo) {
+			p.LastActivity = time.Now()
+		})
+		return nil
+	}
+	if peer.WgIP == "" {
+		return fmt.Errorf("peer %s has no WireGuard IP, cannot materialize", pubkey)
+	}
+
+	_, ipNet, err := net.ParseCIDR(peer.WgIP + "/32")
+	if err != nil {
+		return fmt.Errorf("invalid WgIP for peer %s: %w", pubkey, err)
+	}
+
+	pubkeyBytes, err := base64.StdEncoding.DecodeString(pubkey)
+	if err != nil {
+		return fmt.Errorf("invalid pubkey %s: %w", pubkey, err)
+	}
+	key, err := wgtypes.NewKey(pubkeyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to create key for peer %s: %w", pubkey, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("failed to create wgctrl client: %w", err)
+	}
+	defer client.Close()
+
+	cfg := wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:  key,
+			AllowedIPs: []net.IPNet{*ipNet},
+		}},
+	}
+
+	if err := client.ConfigureDevice(v.WgInterface, cfg); err != nil {
+		return fmt.Errorf("failed to configure device for peer %s: %w", pubkey, err)
+	}
+
+	v.cache.Update(pubkey, func(p *PeerInfo) {
+		p.Installed = true
+		p.LastActivity = time.Now()
+	})
+
+	log.Printf("[valon] Materialized peer %s (IP: %s)", pubkey[:min(len(pubkey), 16)], peer.WgIP)
+	return nil
+}
+
+// deprogramPeer removes a peer from the kernel WireGuard device while
+// leaving its cache/etcd entry untouched, so it can be re-materialized on
+// the next sign of activity.
+func (v *Valon) deprogramPeer(pubkey string) error {
+	peer := v.cache.Get(pubkey)
+	if peer == nil {
+		return fmt.Errorf("peer %s not found in cache", pubkey)
+	}
+
+	pubkeyBytes, err := base64.StdEncoding.DecodeString(pubkey)
+	if err != nil {
+		return fmt.Errorf("invalid pubkey %s: %w", pubkey, err)
+	}
+	key, err := wgtypes.NewKey(pubkeyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to create key for peer %s: %w", pubkey, err)
+	}
+
+	client, err := wgctrl.New()
+	if err != nil {
+		return fmt.Errorf("failed to create wgctrl client: %w", err)
+	}
+	defer client.Close()
+
+	cfg := wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey: key,
+			Remove:    true,
+		}},
+	}
+
+	if err := client.ConfigureDevice(v.WgInterface, cfg); err != nil {
+		return fmt.Errorf("failed to remove peer %s from device: %w", pubkey, err)
+	}
+
+	now := time.Now()
+	v.cache.Update(pubkey, func(p *PeerInfo) {
+		p.Installed = false
+		p.DeprogrammedAt = now
+	})
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package valon
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus collectors exposed by the VALON plugin via CoreDNS's
+// plugin/metrics (the standard /metrics endpoint every CoreDNS instance
+// already serves). peersTotal and etcdSyncErrors are plain counters/gauges;
+// the per-peer ones are vectors labeled by pubkey and alias so operators can
+// graph and alert on an individual peer's connectivity.
+var (
+	peersTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "valon",
+		Name:      "peers_total",
+		Help:      "Number of peers currently known to this node.",
+	})
+
+	peerHandshakeAge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "valon",
+		Name:      "peers_handshake_age_seconds",
+		Help:      "Seconds since the last successful WireGuard handshake with this peer.",
+	}, []string{"pubkey", "alias"})
+
+	peerRxBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "valon",
+		Name:      "peers_rx_bytes_total",
+		Help:      "Bytes received from this peer, as reported by WireGuard.",
+	}, []string{"pubkey", "alias"})
+
+	peerTxBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "valon",
+		Name:      "peers_tx_bytes_total",
+		Help:      "Bytes transmitted to this peer, as reported by WireGuard.",
+	}, []string{"pubkey", "alias"})
+
+	etcdSyncErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "valon",
+		Name:      "etcd_sync_errors_total",
+		Help:      "Total number of errors syncing peer data to the peer store.",
+	})
+
+	dnssecCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "valon",
+		Name:      "dnssec_signature_cache_hits_total",
+		Help:      "Total number of RRSIGs served from the DNSSEC signature cache.",
+	})
+
+	dnssecCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "valon",
+		Name:      "dnssec_signature_cache_misses_total",
+		Help:      "Total number of RRSIGs that required a fresh signing operation.",
+	})
+
+	dnssecSignSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "valon",
+		Name:      "dnssec_sign_seconds",
+		Help:      "Time taken to generate a single RRSIG, for cache misses only.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+var registerMetricsOnce sync.Once
+
+// registerMetrics registers VALON's Prometheus collectors with the default
+// registry. Corefile setup can run more than once in a process (tests,
+// `coredns -plugins`, a config reload), so registration is guarded with a
+// sync.Once to avoid a duplicate-registration panic from MustRegister.
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(peersTotal, peerHandshakeAge, peerRxBytes, peerTxBytes, etcdSyncErrors,
+			dnssecCacheHits, dnssecCacheMisses, dnssecSignSeconds)
+	})
+}
@@ -0,0 +1,266 @@
+package valon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// netmapLongPollTimeout bounds a single poll, and must exceed the
+// coordinator's keepalive interval (valonctl/pkg/netmap's
+// keepaliveInterval) or every idle poll would time out as an error.
+const netmapLongPollTimeout = 30 * time.Second
+
+// netmapMinBackoff and netmapMaxBackoff bound the reconnect delay
+// startNetmapClient applies after a failed poll.
+const (
+	netmapMinBackoff = 1 * time.Second
+	netmapMaxBackoff = 30 * time.Second
+)
+
+// netmapDeltaOp, netmapPeer, netmapDelta, and netmapResponse mirror
+// valonctl/pkg/netmap's DeltaOp/Peer/Delta/Response JSON wire format
+// byte-for-byte. coredns-plugin and valonctl never import each other (see
+// ddns.go's DDNSEndpointRequest/EndpointUpdate for the existing precedent
+// of this kind of duplication), so the contract is kept in sync by hand.
+type netmapDeltaOp string
+
+const (
+	netmapOpPut    netmapDeltaOp = "put"
+	netmapOpDelete netmapDeltaOp = "delete"
+)
+
+type netmapPeer struct {
+	Pubkey        string `json:"pubkey"`
+	Alias         string `json:"alias,omitempty"`
+	WgIP          string `json:"wg_ip,omitempty"`
+	WgIPv6        string `json:"wg_ip6,omitempty"`
+	LANEndpoint   string `json:"lan_endpoint,omitempty"`
+	LANEndpointV6 string `json:"lan_endpoint6,omitempty"`
+	NATEndpoint   string `json:"nat_endpoint,omitempty"`
+	NATEndpointV6 string `json:"nat_endpoint6,omitempty"`
+	StunEndpoint  string `json:"stun_endpoint,omitempty"`
+	NATType       string `json:"nat_type,omitempty"`
+	Role          string `json:"role,omitempty"`
+	RelayedVia    string `json:"relayed_via,omitempty"`
+	IsStatic      bool   `json:"is_static,omitempty"`
+	Endpoint      string `json:"endpoint,omitempty"`
+}
+
+type netmapDelta struct {
+	Op     netmapDeltaOp `json:"op"`
+	Pubkey string        `json:"pubkey"`
+	Peer   *netmapPeer   `json:"peer,omitempty"` // nil when Op is netmapOpDelete
+}
+
+type netmapResponse struct {
+	Version int64 `json:"version"`
+
+	// Compacted means since was older than the coordinator's retained
+	// delta log; Snapshot replaces the cache wholesale and Deltas is empty.
+	Compacted bool          `json:"compacted,omitempty"`
+	Deltas    []netmapDelta `json:"deltas,omitempty"`
+	Snapshot  []*netmapPeer `json:"snapshot,omitempty"`
+}
+
+// netmapVersionTrailer and netmapHashTrailer are the HTTP response trailer
+// names the coordinator sets and the client here checks, so a client can
+// tell a truncated or tampered-with body from a legitimately empty
+// keepalive response.
+const (
+	netmapVersionTrailer = "X-Netmap-Version"
+	netmapHashTrailer    = "X-Netmap-Hash"
+)
+
+// startNetmapClient runs the long-poll netmap client loop until v.stopCh is
+// closed. It is an opt-in alternative to startPeerWatch's direct etcd
+// watch, selected by configuring netmap_url in the Corefile: instead of
+// every CoreDNS instance holding its own etcd watch, they share one
+// coalesced view served by valonctl's coordinator (see
+// valonctl/pkg/netmap), which also gives valonctl a single place to
+// enforce who receives which peers.
+func (v *Valon) startNetmapClient() {
+	log.Printf("[valon] Starting netmap long-poll client against %s", v.NetmapURL)
+
+	httpClient := &http.Client{Timeout: netmapLongPollTimeout}
+	version := int64(0)
+	backoff := netmapMinBackoff
+
+	for {
+		select {
+		case <-v.stopCh:
+			log.Printf("[valon] netmap client stopped")
+			return
+		default:
+		}
+
+		resp, err := v.pollNetmap(httpClient, version)
+		if err != nil {
+			log.Printf("[valon] netmap: poll failed, retrying in %v: %v", backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-v.stopCh:
+				return
+			}
+			backoff = min(backoff*2, netmapMaxBackoff)
+			continue
+		}
+		backoff = netmapMinBackoff
+
+		switch {
+		case resp.Compacted:
+			v.applyNetmapSnapshot(resp.Snapshot)
+			log.Printf("[valon] netmap: since=%d was compacted, applied full snapshot (%d peers) at version %d", version, len(resp.Snapshot), resp.Version)
+		case len(resp.Deltas) > 0:
+			v.applyNetmapDeltas(deltasFromWire(resp.Deltas))
+			log.Printf("[valon] netmap: applied %d deltas, now at version %d", len(resp.Deltas), resp.Version)
+		}
+		version = resp.Version
+	}
+}
+
+// pollNetmap issues a single GET v.NetmapURL?since=since long-poll request
+// and validates the response trailers before returning the decoded body.
+func (v *Valon) pollNetmap(httpClient *http.Client, since int64) (*netmapResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s?since=%d", v.NetmapURL, since), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	httpResp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("coordinator returned status %d", httpResp.StatusCode)
+	}
+
+	var out netmapResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if wantVersion := httpResp.Trailer.Get(netmapVersionTrailer); wantVersion != "" && wantVersion != fmt.Sprintf("%d", out.Version) {
+		return nil, fmt.Errorf("trailer version %q does not match body version %d, possible truncation", wantVersion, out.Version)
+	}
+	if wantHash := httpResp.Trailer.Get(netmapHashTrailer); wantHash != "" {
+		if gotHash := deltaHash(out.Deltas); gotHash != wantHash {
+			return nil, fmt.Errorf("delta hash mismatch (got %s, want %s), gap detected", gotHash, wantHash)
+		}
+	}
+
+	return &out, nil
+}
+
+// deltaHash mirrors valonctl/pkg/netmap's deltaHash byte-for-byte: a
+// sha256 over each delta's op, pubkey, and JSON-encoded peer, in order.
+// It's cheap and order-sensitive, which is all the version+trailer gap
+// check needs.
+func deltaHash(deltas []netmapDelta) string {
+	h := sha256.New()
+	for _, d := range deltas {
+		fmt.Fprintf(h, "%s:%s:", d.Op, d.Pubkey)
+		if d.Peer != nil {
+			b, _ := json.Marshal(d.Peer)
+			h.Write(b)
+		}
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// applyNetmapDeltas applies deltas to v.cache atomically via
+// PeerCache.ApplyDelta, then reconfigures the WireGuard device for any
+// peer whose endpoint changed - the same side effect startPeerWatch's
+// applyPeerEvent has for the direct etcd watch path.
+func (v *Valon) applyNetmapDeltas(deltas []PeerDelta) {
+	type before struct {
+		pubkey string
+		peer   *PeerInfo
+	}
+	prior := make([]before, 0, len(deltas))
+	for _, d := range deltas {
+		if d.Op == PeerDeltaPut {
+			prior = append(prior, before{pubkey: d.Pubkey, peer: v.cache.Get(d.Pubkey)})
+		}
+	}
+
+	v.cache.ApplyDelta(deltas)
+
+	for _, p := range prior {
+		after := v.cache.Get(p.pubkey)
+		if after == nil {
+			continue
+		}
+		endpointChanged := p.peer == nil ||
+			p.peer.LANEndpoint != after.LANEndpoint ||
+			p.peer.NATEndpoint != after.NATEndpoint ||
+			p.peer.Endpoint != after.Endpoint
+		if endpointChanged {
+			v.reconfigurePeerEndpoint(p.pubkey)
+		}
+	}
+}
+
+// applyNetmapSnapshot replaces the cache's contents with snapshot in a
+// single ApplyDelta batch: every snapshot peer becomes a put, and every
+// cached peer absent from snapshot becomes a delete.
+func (v *Valon) applyNetmapSnapshot(snapshot []*netmapPeer) {
+	present := make(map[string]bool, len(snapshot))
+	deltas := make([]PeerDelta, 0, len(snapshot))
+	for _, p := range snapshot {
+		present[p.Pubkey] = true
+		deltas = append(deltas, PeerDelta{Op: PeerDeltaPut, Pubkey: p.Pubkey, Peer: peerFromWire(p)})
+	}
+	for pubkey := range v.cache.GetAll() {
+		if !present[pubkey] {
+			deltas = append(deltas, PeerDelta{Op: PeerDeltaDelete, Pubkey: pubkey})
+		}
+	}
+	v.applyNetmapDeltas(deltas)
+}
+
+// deltasFromWire converts the wire-format deltas of a poll response into
+// PeerDelta batches for PeerCache.ApplyDelta.
+func deltasFromWire(in []netmapDelta) []PeerDelta {
+	out := make([]PeerDelta, 0, len(in))
+	for _, d := range in {
+		switch d.Op {
+		case netmapOpDelete:
+			out = append(out, PeerDelta{Op: PeerDeltaDelete, Pubkey: d.Pubkey})
+		case netmapOpPut:
+			if d.Peer == nil {
+				continue
+			}
+			out = append(out, PeerDelta{Op: PeerDeltaPut, Pubkey: d.Pubkey, Peer: peerFromWire(d.Peer)})
+		}
+	}
+	return out
+}
+
+// peerFromWire converts a wire-format netmapPeer into the PeerInfo
+// ApplyDelta stores in the cache.
+func peerFromWire(p *netmapPeer) *PeerInfo {
+	return &PeerInfo{
+		PubKey:        p.Pubkey,
+		Alias:         p.Alias,
+		WgIP:          p.WgIP,
+		WgIPv6:        p.WgIPv6,
+		LANEndpoint:   p.LANEndpoint,
+		LANEndpointV6: p.LANEndpointV6,
+		NATEndpoint:   p.NATEndpoint,
+		NATEndpointV6: p.NATEndpointV6,
+		StunEndpoint:  p.StunEndpoint,
+		NATType:       p.NATType,
+		Role:          p.Role,
+		RelayedVia:    p.RelayedVia,
+		IsStatic:      p.IsStatic,
+		Endpoint:      p.Endpoint,
+	}
+}
@@ -0,0 +1,118 @@
+package valon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// relaySupervisorInterval is how often checkStuckPeers runs. It doesn't need
+// to be as tight as the WireGuard poll loop since a peer only becomes
+// "stuck" after RelayStuckThreshold of failed handshakes.
+const relaySupervisorInterval = 30 * time.Second
+
+// startRelaySupervisor periodically looks for peers with no working direct
+// path and reroutes them through a relay peer.
+func (v *Valon) startRelaySupervisor() {
+	log.Printf("[valon] Starting relay supervisor (stuck threshold: %v)", v.RelayStuckThreshold)
+
+	ticker := time.NewTicker(relaySupervisorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			v.checkStuckPeers()
+		case <-v.stopCh:
+			log.Printf("[valon] Relay supervisor stopped")
+			return
+		}
+	}
+}
+
+// checkStuckPeers finds peers that have endpoint info on file but haven't
+// completed a handshake in RelayStuckThreshold, and reroutes each through a
+// relay peer's endpoint so they can still reach the rest of the network.
+func (v *Valon) checkStuckPeers() {
+	relay := v.pickRelay()
+	if relay == nil {
+		return // no relay peer available (or configured) yet
+	}
+
+	for pubkey, peer := range v.cache.GetAll() {
+		if peer.Role == "relay" || peer.RelayedVia != "" {
+			continue // relays don't relay themselves, and we don't re-relay
+		}
+		if peer.LANEndpoint == "" && peer.NATEndpoint == "" && peer.StunEndpoint == "" {
+			continue // no endpoint attempt has been made yet; give DDNS/STUN a chance first
+		}
+		// A peer with no handshake on record at all counts as stuck as soon
+		// as it has an endpoint; how long it has been known is not checked.
+		if time.Since(peer.LastHandshake) < v.RelayStuckThreshold {
+			continue // recently handshaked
+		}
+
+		if err := v.relayPeerThrough(pubkey, relay); err != nil {
+			log.Printf("[valon] Failed to relay stuck peer %s via %s: %v",
+				pubkey[:min(len(pubkey), 16)], relay.PubKey[:min(len(relay.PubKey), 16)], err)
+			continue
+		}
+
+		log.Printf("[valon] Peer %s stuck for >%v, rerouted through relay %s",
+			pubkey[:min(len(pubkey), 16)], v.RelayStuckThreshold, relay.PubKey[:min(len(relay.PubKey), 16)])
+	}
+}
+
+// pickRelay returns the first known peer with Role "relay" and a usable
+// endpoint. Peers are visited in map iteration order, so which relay is
+// picked is not deterministic across calls when more than one is
+// available; that's fine since all relays are interchangeable for a given
+// destination pubkey.
+func (v *Valon) pickRelay() *PeerInfo {
+	for _, peer := range v.cache.GetAll() {
+		if peer.Role != "relay" {
+			continue
+		}
+		if peer.LANEndpoint != "" || peer.NATEndpoint != "" {
+			return peer
+		}
+	}
+	return nil
+}
+
+// relayPeerThrough rewrites pubkey's endpoints/nated in etcd to point at
+// relay's endpoint, and marks /valon/peers/<pubkey>/relay with relay's
+// pubkey as a marker that this is a relayed, not a direct, endpoint.
+func (v *Valon) relayPeerThrough(pubkey string, relay *PeerInfo) error {
+	relayEndpoint := relay.LANEndpoint
+	if relayEndpoint == "" {
+		relayEndpoint = relay.NATEndpoint
+	}
+	if relayEndpoint == "" {
+		return fmt.Errorf("relay %s has no usable endpoint", relay.PubKey)
+	}
+	if v.etcdClient == nil {
+		return fmt.Errorf("relay rerouting requires the etcd backend")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	natedKey := fmt.Sprintf("/valon/peers/%s/endpoints/nated", pubkey)
+	relayMarkerKey := fmt.Sprintf("/valon/peers/%s/relay", pubkey)
+
+	if _, err := v.etcdClient.Put(ctx, natedKey, relayEndpoint); err != nil {
+		return fmt.Errorf("failed to write relayed endpoint: %w", err)
+	}
+	if _, err := v.etcdClient.Put(ctx, relayMarkerKey, relay.PubKey); err != nil {
+		return fmt.Errorf("failed to write relay marker: %w", err)
+	}
+
+	v.cache.Update(pubkey, func(p *PeerInfo) {
+		p.NATEndpoint = relayEndpoint
+		p.RelayedVia = relay.PubKey
+	})
+
+	return nil
+}
@@ -33,6 +33,24 @@ func setup(c *caddy.Controller) error {
 		// Parse block directives
 		for c.NextBlock() {
 			switch c.Val() {
+			case "backend":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return c.ArgErr()
+				}
+				switch args[0] {
+				case "etcd":
+					v.Backend = "etcd"
+				case "file":
+					if len(args) < 2 {
+						return c.Errf("backend file requires a path, e.g. `backend file /var/lib/valon/peers.json`")
+					}
+					v.Backend = "file"
+					v.BackendPath = args[1]
+				default:
+					return c.Errf("invalid backend %q: must be etcd or file", args[0])
+				}
+
 			case "etcd_endpoints":
 				args := c.RemainingArgs()
 				if len(args) == 0 {
@@ -72,6 +90,67 @@ func setup(c *caddy.Controller) error {
 				}
 				v.EtcdSyncInterval = duration
 
+			case "lazy_peers":
+				v.LazyPeers = true
+				// Optional argument: the idle threshold as a bare duration
+				// (e.g. `lazy_peers 5m`), same as wg_lazy_idle_threshold.
+				if args := c.RemainingArgs(); len(args) > 0 {
+					duration, err := time.ParseDuration(args[0])
+					if err != nil {
+						return c.Errf("invalid lazy_peers idle threshold: %v", err)
+					}
+					v.LazyIdleThreshold = duration
+				}
+
+			case "wg_lazy_idle_threshold":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				duration, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return c.Errf("invalid wg_lazy_idle_threshold: %v", err)
+				}
+				v.LazyIdleThreshold = duration
+				v.LazyPeers = true
+
+			case "relay_stuck_threshold":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				duration, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return c.Errf("invalid relay_stuck_threshold: %v", err)
+				}
+				v.RelayStuckThreshold = duration
+
+			case "dnssec":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return c.Errf("dnssec requires a ZSK path, e.g. `dnssec /etc/valon/zsk.pem [/etc/valon/ksk.pem]`")
+				}
+				v.DNSSECZSKPath = args[0]
+				if len(args) > 1 {
+					v.DNSSECKSKPath = args[1]
+				}
+
+			case "netmap_url":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				v.NetmapURL = c.Val()
+
+			case "auth_mode":
+				if !c.NextArg() {
+					return c.ArgErr()
+				}
+				mode := c.Val()
+				switch mode {
+				case "ip", "signature", "both":
+					v.DDNSAuthMode = mode
+				default:
+					return c.Errf("invalid auth_mode %q: must be ip, signature, or both", mode)
+				}
+
 			default:
 				return c.Errf("unknown property '%s'", c.Val())
 			}
@@ -0,0 +1,56 @@
+package valon
+
+import "context"
+
+// PeerEventType distinguishes a Put (create/update) from a Delete in a
+// PeerStore watch stream.
+type PeerEventType int
+
+const (
+	PeerEventPut PeerEventType = iota
+	PeerEventDelete
+)
+
+// PeerEvent is a single change to a peer record, as delivered by
+// PeerStore.Watch. Peer always carries the full, current record for a Put;
+// for a Delete only Peer.PubKey is guaranteed set.
+type PeerEvent struct {
+	Type PeerEventType
+	Peer *PeerInfo
+}
+
+// PeerStore abstracts the durable store backing the plugin's peer cache, so
+// the core of the plugin (cache population, restore-on-startup, DDNS
+// endpoint updates) doesn't care whether peer records live in etcd, a
+// single JSON file, or (for tests) a plain in-memory map.
+//
+// Side-channel features that etcd happens to make convenient - the alias
+// secondary index (/valon/aliases/<alias>), Ed25519 identity_pub lookups for
+// signature-based DDNS auth, and the relay marker key - are not part of
+// this interface. They remain direct v.etcdClient calls for now, guarded to
+// degrade gracefully (logging rather than panicking) when running against a
+// non-etcd backend; unifying them behind PeerStore is future work.
+type PeerStore interface {
+	// Get loads one peer by public key. It returns (nil, nil), not an
+	// error, if the peer is not found - matching PeerCache.Get.
+	Get(ctx context.Context, pubkey string) (*PeerInfo, error)
+
+	// List loads every known peer.
+	List(ctx context.Context) ([]*PeerInfo, error)
+
+	// Put creates or updates a peer record. MemoryStore and FileStore
+	// replace the record in full; EtcdStore writes only peer's non-empty
+	// fields, so a field that is empty in peer keeps its stored value.
+	Put(ctx context.Context, peer *PeerInfo) error
+
+	// Delete removes a peer record. It is not an error if pubkey is absent.
+	Delete(ctx context.Context, pubkey string) error
+
+	// Watch streams PeerEvents for changes made by any writer, including
+	// this process's own Put/Delete calls and, for backends that support
+	// it, other processes' writes (e.g. a peer registering via valonctl).
+	// The returned channel is closed when ctx is done. A fallback from a
+	// native watch to polling (see EtcdStore.Watch) happens inside the
+	// store and keeps the channel open.
+	Watch(ctx context.Context) (<-chan PeerEvent, error)
+}
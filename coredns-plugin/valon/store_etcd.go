@@ -0,0 +1,355 @@
+package valon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// peersPrefix is the etcd key prefix under which every peer record lives,
+// as /valon/peers/<pubkey>/<field>.
+const peersPrefix = "/valon/peers/"
+
+// EtcdStore is the etcd-backed PeerStore. Each PeerInfo field is stored as
+// its own key under /valon/peers/<pubkey>/ (wg_ip, alias, endpoints/lan,
+// endpoints/nated, endpoints/stun, nat_type, role, relay, endpoint,
+// static) rather than one serialized blob, matching the key schema
+// valonctl writes to directly and the layout the plugin historically
+// parsed by hand in loadFromEtcd/loadPeerFromEtcd before this file existed.
+type EtcdStore struct {
+	client *clientv3.Client
+
+	// syncInterval is the poll cadence Watch falls back to once it gives up
+	// on the native etcd watch (see maxConsecutiveCompactions).
+	syncInterval time.Duration
+}
+
+// NewEtcdStore wraps an existing etcd client as a PeerStore.
+func NewEtcdStore(client *clientv3.Client, syncInterval time.Duration) *EtcdStore {
+	return &EtcdStore{client: client, syncInterval: syncInterval}
+}
+
+func (s *EtcdStore) Get(ctx context.Context, pubkey string) (*PeerInfo, error) {
+	resp, err := s.client.Get(ctx, peersPrefix+pubkey+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("etcd get failed: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+
+	peer := &PeerInfo{PubKey: pubkey}
+	for _, kv := range resp.Kvs {
+		applyEtcdField(peer, strings.TrimPrefix(string(kv.Key), peersPrefix+pubkey+"/"), string(kv.Value))
+		if kv.ModRevision > peer.etcdRevision {
+			peer.etcdRevision = kv.ModRevision
+		}
+	}
+	return peer, nil
+}
+
+func (s *EtcdStore) List(ctx context.Context) ([]*PeerInfo, error) {
+	resp, err := s.client.Get(ctx, peersPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("etcd get failed: %w", err)
+	}
+
+	byPubkey := make(map[string]*PeerInfo)
+	for _, kv := range resp.Kvs {
+		relKey := strings.TrimPrefix(string(kv.Key), peersPrefix)
+		pubkey, field, ok := splitPeerKey(relKey)
+		if !ok {
+			continue
+		}
+
+		peer := byPubkey[pubkey]
+		if peer == nil {
+			peer = &PeerInfo{PubKey: pubkey}
+			byPubkey[pubkey] = peer
+		}
+		applyEtcdField(peer, field, string(kv.Value))
+		if kv.ModRevision > peer.etcdRevision {
+			peer.etcdRevision = kv.ModRevision
+		}
+	}
+
+	out := make([]*PeerInfo, 0, len(byPubkey))
+	for _, peer := range byPubkey {
+		out = append(out, peer)
+	}
+	return out, nil
+}
+
+func (s *EtcdStore) Put(ctx context.Context, peer *PeerInfo) error {
+	ops := []clientv3.Op{}
+	put := func(field, value string) {
+		if value == "" {
+			return
+		}
+		ops = append(ops, clientv3.OpPut(peersPrefix+peer.PubKey+"/"+field, value))
+	}
+
+	put("wg_ip", peer.WgIP)
+	put("wg_ip6", peer.WgIPv6)
+	put("alias", peer.Alias)
+	put("endpoints/lan", peer.LANEndpoint)
+	put("endpoints/lan6", peer.LANEndpointV6)
+	put("endpoints/stun", peer.StunEndpoint)
+	put("nat_type", peer.NATType)
+	put("role", peer.Role)
+	put("relay", peer.RelayedVia)
+	put("endpoint", peer.Endpoint)
+	if peer.IsStatic {
+		put("static", "true")
+	}
+	// The canonical "nated" endpoint prefers the self-reported STUN value
+	// over one merely observed from an inbound handshake (see
+	// bestNATEndpoint in etcd_sync.go).
+	put("endpoints/nated", bestNATEndpoint(peer))
+	put("endpoints/nated6", peer.NATEndpointV6)
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	txn := s.client.Txn(ctx)
+	if peer.etcdRevision > 0 {
+		// Compare-and-swap: write only if none of this peer's keys changed
+		// after the highest revision last observed for it.
+		txn = txn.If(clientv3.Compare(clientv3.ModRevision(peersPrefix+peer.PubKey+"/"), "<", peer.etcdRevision+1).WithPrefix())
+	}
+	resp, err := txn.Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("etcd transaction failed: %w", err)
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("peer %s changed in etcd after revision %d, not overwriting", peer.PubKey, peer.etcdRevision)
+	}
+	return nil
+}
+
+func (s *EtcdStore) Delete(ctx context.Context, pubkey string) error {
+	if _, err := s.client.Delete(ctx, peersPrefix+pubkey+"/", clientv3.WithPrefix()); err != nil {
+		return fmt.Errorf("etcd delete failed: %w", err)
+	}
+	return nil
+}
+
+// Watch starts a background goroutine that turns raw etcd watch events on
+// /valon/peers/ into PeerEvents, resuming from the revision of its own
+// initial List so nothing racing the first snapshot is missed (see
+// watchFromRevision), and falls back to polling List on syncInterval if the
+// watch is compacted maxConsecutiveCompactions times in a row.
+func (s *EtcdStore) Watch(ctx context.Context) (<-chan PeerEvent, error) {
+	listResp, err := s.client.Get(ctx, peersPrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, fmt.Errorf("etcd get failed: %w", err)
+	}
+
+	out := make(chan PeerEvent, 16)
+	go s.runWatch(ctx, out, listResp.Header.Revision)
+	return out, nil
+}
+
+func (s *EtcdStore) runWatch(ctx context.Context, out chan<- PeerEvent, fromRevision int64) {
+	defer close(out)
+
+	rev := fromRevision
+	consecutiveCompactions := 0
+	watchCh := s.watchFromRevision(ctx, rev+1)
+
+	for {
+		select {
+		case resp, ok := <-watchCh:
+			if !ok {
+				watchCh = s.watchFromRevision(ctx, rev+1)
+				continue
+			}
+			if resp.Canceled {
+				if errors.Is(resp.Err(), rpctypes.ErrCompacted) {
+					consecutiveCompactions++
+					if consecutiveCompactions >= maxConsecutiveCompactions {
+						log.Printf("[valon] etcd store watch compacted %d times in a row, falling back to polling", consecutiveCompactions)
+						s.pollUntilDone(ctx, out)
+						return
+					}
+					log.Printf("[valon] etcd store watch compacted (%d/%d), resyncing", consecutiveCompactions, maxConsecutiveCompactions)
+					r, err := s.emitFullResync(ctx, out)
+					if err != nil {
+						log.Printf("[valon] Warning: resync after compaction failed: %v", err)
+					} else {
+						rev = r
+					}
+					watchCh = s.watchFromRevision(ctx, rev+1)
+					continue
+				}
+				log.Printf("[valon] etcd store watch canceled (%v), restarting", resp.Err())
+				watchCh = s.watchFromRevision(ctx, rev+1)
+				continue
+			}
+
+			consecutiveCompactions = 0
+			for _, ev := range resp.Events {
+				if ev.Kv.ModRevision > rev {
+					rev = ev.Kv.ModRevision
+				}
+				s.emitEvent(ctx, out, ev)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// watchFromRevision opens a watch on /valon/peers/ starting at fromRevision
+// (inclusive). A fromRevision <= 0 means "start from the current revision".
+func (s *EtcdStore) watchFromRevision(ctx context.Context, fromRevision int64) clientv3.WatchChan {
+	if fromRevision > 0 {
+		return s.client.Watch(ctx, peersPrefix, clientv3.WithPrefix(), clientv3.WithRev(fromRevision))
+	}
+	return s.client.Watch(ctx, peersPrefix, clientv3.WithPrefix())
+}
+
+// emitEvent reconstructs the full current PeerInfo for the peer a single
+// raw etcd event touched and emits it as a PeerEvent, rather than trying to
+// patch just the one changed field - the watch stream is low-volume enough
+// that the extra Get is cheap, and this keeps PeerEvent.Peer always a
+// complete, consistent record.
+func (s *EtcdStore) emitEvent(ctx context.Context, out chan<- PeerEvent, ev *clientv3.Event) {
+	relKey := strings.TrimPrefix(string(ev.Kv.Key), peersPrefix)
+	pubkey, field, ok := splitPeerKey(relKey)
+	if !ok {
+		return
+	}
+
+	if ev.Type == clientv3.EventTypeDelete {
+		// Only the deletion of the primary key means the whole peer is
+		// gone; deletion of a single field key is rare and not otherwise
+		// meaningful here.
+		if field == "wg_ip" {
+			out <- PeerEvent{Type: PeerEventDelete, Peer: &PeerInfo{PubKey: pubkey}}
+		}
+		return
+	}
+
+	peer, err := s.Get(ctx, pubkey)
+	if err != nil {
+		log.Printf("[valon] etcd store: failed to reload peer %s after watch event: %v", pubkey, err)
+		return
+	}
+	if peer == nil {
+		return
+	}
+	out <- PeerEvent{Type: PeerEventPut, Peer: peer}
+}
+
+// emitFullResync re-lists every peer and emits each as a Put event, for use
+// after a compaction means the watch can no longer resume incrementally.
+// It returns the revision the resync was read at.
+func (s *EtcdStore) emitFullResync(ctx context.Context, out chan<- PeerEvent) (int64, error) {
+	resp, err := s.client.Get(ctx, peersPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, fmt.Errorf("etcd get failed: %w", err)
+	}
+
+	byPubkey := make(map[string]*PeerInfo)
+	for _, kv := range resp.Kvs {
+		pubkey, field, ok := splitPeerKey(strings.TrimPrefix(string(kv.Key), peersPrefix))
+		if !ok {
+			continue
+		}
+		peer := byPubkey[pubkey]
+		if peer == nil {
+			peer = &PeerInfo{PubKey: pubkey}
+			byPubkey[pubkey] = peer
+		}
+		applyEtcdField(peer, field, string(kv.Value))
+	}
+
+	for _, peer := range byPubkey {
+		out <- PeerEvent{Type: PeerEventPut, Peer: peer}
+	}
+
+	return resp.Header.Revision, nil
+}
+
+// pollUntilDone is the fallback once the watch gives up: it periodically
+// re-lists and emits a full resync instead of incremental events.
+func (s *EtcdStore) pollUntilDone(ctx context.Context, out chan<- PeerEvent) {
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.emitFullResync(ctx, out); err != nil {
+				log.Printf("[valon] Warning: periodic etcd store poll failed: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// splitPeerKey splits a key relative to peersPrefix (i.e. "<pubkey>/<field>"
+// where pubkey itself may contain "/" as part of its base64 encoding) into
+// the pubkey and field parts, using the same known-field-suffix matching
+// loadFromEtcd has always used. The IPv6 fields (wg_ip6, endpoints/lan6,
+// endpoints/nated6) need no markers of their own: "/wg_ip" and
+// "/endpoints/lan"/"/endpoints/nated" already match them as a prefix, and
+// strings.Index returns the same split point either way.
+func splitPeerKey(relKey string) (pubkey, field string, ok bool) {
+	for _, marker := range []string{
+		"/wg_ip", "/alias", "/endpoints/lan", "/endpoints/nated", "/endpoints/stun",
+		"/nat_type", "/role", "/relay", "/static",
+	} {
+		if idx := strings.Index(relKey, marker); idx != -1 {
+			return relKey[:idx], relKey[idx+1:], true
+		}
+	}
+	// "/endpoint" must be checked after "/endpoints/..." since that also
+	// contains "/endpoint" as a substring.
+	if idx := strings.Index(relKey, "/endpoint"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	return "", "", false
+}
+
+// applyEtcdField sets the PeerInfo field named by its etcd key suffix (see
+// splitPeerKey) to value.
+func applyEtcdField(peer *PeerInfo, field, value string) {
+	switch field {
+	case "wg_ip":
+		peer.WgIP = value
+	case "wg_ip6":
+		peer.WgIPv6 = value
+	case "alias":
+		peer.Alias = value
+	case "endpoints/lan":
+		peer.LANEndpoint = value
+	case "endpoints/lan6":
+		peer.LANEndpointV6 = value
+	case "endpoints/nated":
+		peer.NATEndpoint = value
+	case "endpoints/nated6":
+		peer.NATEndpointV6 = value
+	case "endpoints/stun":
+		peer.StunEndpoint = value
+	case "nat_type":
+		peer.NATType = value
+	case "role":
+		peer.Role = value
+	case "relay":
+		peer.RelayedVia = value
+	case "endpoint":
+		peer.Endpoint = value
+	case "static":
+		peer.IsStatic = value == "true"
+	}
+}
@@ -0,0 +1,154 @@
+package valon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileStore is a PeerStore backed by a single JSON file, for small
+// deployments that don't want to run etcd. All peers are read into memory
+// on NewFileStore and the whole file is rewritten on every Put/Delete.
+//
+// Watch only reports this process's own Put/Delete calls; unlike etcd,
+// a plain JSON file has no built-in change notification, so a second
+// process editing the file (or another valon node, in a multi-node
+// setup) is only picked up the next time something calls List.
+type FileStore struct {
+	path string
+
+	mu       sync.Mutex
+	peers    map[string]*PeerInfo
+	watchers []chan PeerEvent
+}
+
+// NewFileStore loads peers from path, creating an empty store if the file
+// does not yet exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, peers: make(map[string]*PeerInfo)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read peer store file %s: %w", path, err)
+	}
+	if len(data) == 0 {
+		return s, nil
+	}
+
+	var peers []*PeerInfo
+	if err := json.Unmarshal(data, &peers); err != nil {
+		return nil, fmt.Errorf("failed to parse peer store file %s: %w", path, err)
+	}
+	for _, peer := range peers {
+		s.peers[peer.PubKey] = peer
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, pubkey string) (*PeerInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peer := s.peers[pubkey]
+	if peer == nil {
+		return nil, nil
+	}
+	clone := *peer
+	return &clone, nil
+}
+
+func (s *FileStore) List(ctx context.Context) ([]*PeerInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*PeerInfo, 0, len(s.peers))
+	for _, peer := range s.peers {
+		clone := *peer
+		out = append(out, &clone)
+	}
+	return out, nil
+}
+
+func (s *FileStore) Put(ctx context.Context, peer *PeerInfo) error {
+	clone := *peer
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.peers[peer.PubKey] = &clone
+	if err := s.saveLocked(); err != nil {
+		return err
+	}
+	s.notifyLocked(PeerEvent{Type: PeerEventPut, Peer: &clone})
+	return nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, pubkey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.peers, pubkey)
+	if err := s.saveLocked(); err != nil {
+		return err
+	}
+	s.notifyLocked(PeerEvent{Type: PeerEventDelete, Peer: &PeerInfo{PubKey: pubkey}})
+	return nil
+}
+
+func (s *FileStore) Watch(ctx context.Context) (<-chan PeerEvent, error) {
+	ch := make(chan PeerEvent, 16)
+
+	s.mu.Lock()
+	s.watchers = append(s.watchers, ch)
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, w := range s.watchers {
+			if w == ch {
+				s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// saveLocked rewrites the whole file from the in-memory map. Callers must
+// hold s.mu.
+func (s *FileStore) saveLocked() error {
+	peers := make([]*PeerInfo, 0, len(s.peers))
+	for _, peer := range s.peers {
+		peers = append(peers, peer)
+	}
+
+	data, err := json.MarshalIndent(peers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal peer store: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write peer store file %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// notifyLocked fans out an event to every active watcher. Callers must hold
+// s.mu. A slow watcher never blocks a write: its event is dropped if its
+// buffer is full.
+func (s *FileStore) notifyLocked(ev PeerEvent) {
+	for _, ch := range s.watchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package valon
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is a PeerStore backed by a plain in-memory map. It exists
+// primarily for tests, replacing ad-hoc mocks of the etcd client, but it is
+// also a valid (non-durable) Backend choice for a throwaway or single-run
+// deployment.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	peers    map[string]*PeerInfo
+	watchers []chan PeerEvent
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{peers: make(map[string]*PeerInfo)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, pubkey string) (*PeerInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	peer := s.peers[pubkey]
+	if peer == nil {
+		return nil, nil
+	}
+	clone := *peer
+	return &clone, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]*PeerInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*PeerInfo, 0, len(s.peers))
+	for _, peer := range s.peers {
+		clone := *peer
+		out = append(out, &clone)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, peer *PeerInfo) error {
+	clone := *peer
+	s.mu.Lock()
+	s.peers[peer.PubKey] = &clone
+	s.notifyLocked(PeerEvent{Type: PeerEventPut, Peer: &clone})
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, pubkey string) error {
+	s.mu.Lock()
+	delete(s.peers, pubkey)
+	s.notifyLocked(PeerEvent{Type: PeerEventDelete, Peer: &PeerInfo{PubKey: pubkey}})
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Watch(ctx context.Context) (<-chan PeerEvent, error) {
+	ch := make(chan PeerEvent, 16)
+
+	s.mu.Lock()
+	s.watchers = append(s.watchers, ch)
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, w := range s.watchers {
+			if w == ch {
+				s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+// notifyLocked fans out an event to every active watcher. Callers must hold
+// s.mu. A slow watcher never blocks a write: its event is dropped if its
+// buffer is full.
+func (s *MemoryStore) notifyLocked(ev PeerEvent) {
+	for _, ch := range s.watchers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package valon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// tsigKeySuffix is the fixed suffix peer TSIG key names are minted under:
+// "<base32-label>._valon.". This is deliberately not a name under v.Zone -
+// a TSIG key name is a credential identifier, not something ever meant to
+// be queried or resolved.
+const tsigKeySuffix = "._valon."
+
+// defaultWireGuardPort is the port assumed for a LAN endpoint registered via
+// an UPDATE's bare A record, which (unlike the DDNS HTTP API's "IP:PORT"
+// lan_endpoint field) has no way to carry a port. A peer listening on a
+// non-default port must keep using the DDNS HTTP API instead.
+const defaultWireGuardPort = 51820
+
+// handleUpdate implements RFC 2136 Dynamic UPDATE, authenticated with TSIG
+// (RFC 2845), as an in-protocol alternative to the plaintext DDNS HTTP API
+// (see ddns.go): a peer can register or clear its own LAN endpoint by
+// sending a TSIG-signed UPDATE instead of POSTing to /api/endpoint[/delete].
+//
+// Only the TSIG key owner's own name, lan.<label>.v.Zone, may appear in the
+// Update section: an ADD of an A record there becomes a PeerCache.Update
+// writing LANEndpoint, and a DELETE (RRset or name) of it clears
+// LANEndpoint. An Update section naming anything else is refused outright
+// with RcodeRefused, rather than applying the in-namespace RRs and silently
+// ignoring the rest.
+func (v Valon) handleUpdate(w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA {
+		m.Rcode = dns.RcodeFormatError
+		w.WriteMsg(m)
+		return dns.RcodeFormatError, nil
+	}
+
+	zone := r.Question[0].Name
+	if zone != v.Zone {
+		m.Rcode = dns.RcodeNotZone
+		w.WriteMsg(m)
+		return dns.RcodeNotZone, nil
+	}
+
+	label, err := v.verifyUpdateTsig(r)
+	if err != nil {
+		log.Printf("[valon] UPDATE: TSIG verification failed: %v", err)
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return dns.RcodeRefused, nil
+	}
+
+	pubkey, err := dnsLabelToPubkey(label)
+	if err != nil {
+		log.Printf("[valon] UPDATE: TSIG key label %q does not decode to a peer pubkey: %v", label, err)
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return dns.RcodeRefused, nil
+	}
+
+	if v.cache.Get(pubkey) == nil {
+		log.Printf("[valon] UPDATE: no cached peer for TSIG label %q", label)
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return dns.RcodeRefused, nil
+	}
+
+	applied, refused := v.applyUpdateRRs(pubkey, label, r.Ns)
+	if refused {
+		m.Rcode = dns.RcodeRefused
+		w.WriteMsg(m)
+		return dns.RcodeRefused, nil
+	}
+	if !applied {
+		log.Printf("[valon] UPDATE: no applicable RRs in update section for peer %s", label)
+	}
+
+	m.Rcode = dns.RcodeSuccess
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, nil
+}
+
+// applyUpdateRRs applies an UPDATE's Update section (ns) on behalf of the
+// peer identified by pubkey/label. Every RR in ns must name the peer's own
+// lan.<label>.v.Zone; if any names something else, nothing in ns is applied
+// and refused is true so the caller can answer RcodeRefused. Otherwise an
+// ADD of an A record sets LANEndpoint and a DELETE (of the RRset or of the
+// whole name, both of which arrive with class ANY) clears it; applied
+// reports whether any such change was made.
+func (v Valon) applyUpdateRRs(pubkey, label string, ns []dns.RR) (applied, refused bool) {
+	ownName := "lan." + label + "." + v.Zone
+
+	for _, rr := range ns {
+		hdr := rr.Header()
+		if !strings.EqualFold(hdr.Name, ownName) {
+			log.Printf("[valon] UPDATE: peer %s attempted to modify %s, refusing", label, hdr.Name)
+			return false, true
+		}
+
+		switch a := rr.(type) {
+		case *dns.A:
+			switch hdr.Class {
+			case dns.ClassINET:
+				lanEndpoint := net.JoinHostPort(a.A.String(), fmt.Sprintf("%d", defaultWireGuardPort))
+				v.cache.Update(pubkey, func(p *PeerInfo) {
+					p.LANEndpoint = lanEndpoint
+				})
+				applied = true
+				log.Printf("[valon] UPDATE: set LAN endpoint for peer %s: %s", label, lanEndpoint)
+
+			case dns.ClassANY, dns.ClassNONE:
+				// A delete-RRset (RemoveRRset, class ANY) or delete-exact-RR
+				// (Remove, class NONE) of the A record unpacks as *dns.A
+				// too, with empty rdata - both mean the same thing here
+				// since the peer's own name only ever carries one A record.
+				v.cache.Update(pubkey, func(p *PeerInfo) {
+					p.LANEndpoint = ""
+				})
+				applied = true
+				log.Printf("[valon] UPDATE: cleared LAN endpoint for peer %s", label)
+			}
+
+		default:
+			if hdr.Class == dns.ClassANY {
+				v.cache.Update(pubkey, func(p *PeerInfo) {
+					p.LANEndpoint = ""
+				})
+				applied = true
+				log.Printf("[valon] UPDATE: cleared LAN endpoint for peer %s", label)
+			}
+		}
+	}
+
+	return applied, false
+}
+
+// peerLabelFromTsigKeyName strips tsigKeySuffix from a TSIG key name,
+// reporting an error if name doesn't carry it.
+func peerLabelFromTsigKeyName(name string) (string, error) {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, tsigKeySuffix) {
+		return "", fmt.Errorf("TSIG key name %q is not a recognized peer key", name)
+	}
+	return strings.TrimSuffix(name, tsigKeySuffix), nil
+}
+
+// verifyTsigSecret validates r's TSIG RR against secret (already resolved
+// by the caller) and returns the signer's base32 peer label on success.
+// Split out from verifyUpdateTsig so the wire-level verification can be
+// exercised directly in tests without an etcd backend.
+func verifyTsigSecret(r *dns.Msg, secret string) (string, error) {
+	tsig := r.IsTsig()
+	if tsig == nil {
+		return "", fmt.Errorf("request is not TSIG-signed")
+	}
+
+	label, err := peerLabelFromTsigKeyName(tsig.Hdr.Name)
+	if err != nil {
+		return "", err
+	}
+
+	buf, err := r.Pack()
+	if err != nil {
+		return "", fmt.Errorf("failed to pack message for TSIG verification: %w", err)
+	}
+
+	if err := dns.TsigVerify(buf, secret, "", false); err != nil {
+		return "", fmt.Errorf("TSIG verification failed: %w", err)
+	}
+
+	return label, nil
+}
+
+// verifyUpdateTsig resolves the shared secret for r's TSIG key via etcd and
+// validates r against it, returning the signer's base32 peer label.
+func (v Valon) verifyUpdateTsig(r *dns.Msg) (string, error) {
+	tsig := r.IsTsig()
+	if tsig == nil {
+		return "", fmt.Errorf("request is not TSIG-signed")
+	}
+
+	label, err := peerLabelFromTsigKeyName(tsig.Hdr.Name)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := v.lookupTsigSecret(label)
+	if err != nil {
+		return "", err
+	}
+
+	return verifyTsigSecret(r, secret)
+}
+
+// lookupTsigSecret fetches the base64 TSIG shared secret provisioned for
+// label at /valon/tsig/<label> (see client.EtcdClient.AddPeer). Like the
+// alias and identity_pub side-channel lookups in ddns.go and auth.go, this
+// requires the etcd backend.
+func (v Valon) lookupTsigSecret(label string) (string, error) {
+	if v.etcdClient == nil {
+		return "", fmt.Errorf("TSIG authentication requires the etcd backend")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	key := fmt.Sprintf("/valon/tsig/%s", label)
+	resp, err := v.etcdClient.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("etcd get failed: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", fmt.Errorf("no TSIG secret registered for label %q", label)
+	}
+
+	return string(resp.Kvs[0].Value), nil
+}
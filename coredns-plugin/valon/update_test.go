@@ -0,0 +1,292 @@
+package valon
+
+import (
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const testTsigSecret = "3q2+7w=="
+
+// signUpdate builds an UPDATE message for zone, appends ns to its Update
+// section, TSIG-signs it for keyName/secret using dns.TsigGenerate (the
+// same wire-level signing a real dns.Client performs before sending), and
+// returns the unpacked result - i.e. exactly what a CoreDNS listener would
+// hand to ServeDNS after reading it off the wire.
+func signUpdate(t *testing.T, zone, keyName, secret string, ns []dns.RR) *dns.Msg {
+	t.Helper()
+
+	m := new(dns.Msg)
+	m.SetUpdate(zone)
+	m.Ns = ns
+	m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+
+	buf, _, err := dns.TsigGenerate(m, secret, "", false)
+	if err != nil {
+		t.Fatalf("TsigGenerate: %v", err)
+	}
+
+	r := new(dns.Msg)
+	if err := r.Unpack(buf); err != nil {
+		t.Fatalf("Unpack signed message: %v", err)
+	}
+	return r
+}
+
+func aRecord(name, ip string) *dns.A {
+	return &dns.A{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+		A:   net.ParseIP(ip),
+	}
+}
+
+func deleteRRset(name string, rrtype uint16) dns.RR {
+	return &dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassANY, Ttl: 0, Rdlength: 0}
+}
+
+func TestVerifyTsigSecret(t *testing.T) {
+	r := signUpdate(t, "valon.internal.", "mfrggzdf._valon.", testTsigSecret, nil)
+
+	label, err := verifyTsigSecret(r, testTsigSecret)
+	if err != nil {
+		t.Fatalf("verifyTsigSecret() error = %v, want nil", err)
+	}
+	if label != "mfrggzdf" {
+		t.Errorf("label = %q, want %q", label, "mfrggzdf")
+	}
+}
+
+func TestVerifyTsigSecretWrongSecret(t *testing.T) {
+	r := signUpdate(t, "valon.internal.", "mfrggzdf._valon.", testTsigSecret, nil)
+
+	otherSecret := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	if _, err := verifyTsigSecret(r, otherSecret); err == nil {
+		t.Fatal("verifyTsigSecret() with wrong secret = nil error, want error")
+	}
+}
+
+func TestVerifyTsigSecretNotSigned(t *testing.T) {
+	r := new(dns.Msg)
+	r.SetUpdate("valon.internal.")
+
+	if _, err := verifyTsigSecret(r, testTsigSecret); err == nil {
+		t.Fatal("verifyTsigSecret() on an unsigned message = nil error, want error")
+	}
+}
+
+func TestPeerLabelFromTsigKeyName(t *testing.T) {
+	tests := []struct {
+		name    string
+		label   string
+		wantErr bool
+	}{
+		{"mfrggzdf._valon.", "mfrggzdf", false},
+		{"MFRGGZDF._valon.", "mfrggzdf", false},
+		{"mfrggzdf.example.", "", true},
+		{"notsuffixed", "", true},
+	}
+	for _, tt := range tests {
+		got, err := peerLabelFromTsigKeyName(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("peerLabelFromTsigKeyName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.label {
+			t.Errorf("peerLabelFromTsigKeyName(%q) = %q, want %q", tt.name, got, tt.label)
+		}
+	}
+}
+
+func TestApplyUpdateRRsAddSetsLANEndpoint(t *testing.T) {
+	v := Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	pubkey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	label, err := pubkeyToDnsLabel(pubkey)
+	if err != nil {
+		t.Fatalf("pubkeyToDnsLabel: %v", err)
+	}
+	v.cache.Set(pubkey, &PeerInfo{PubKey: pubkey, WgIP: "100.64.0.5"})
+
+	ownName := "lan." + label + ".valon.internal."
+	applied, refused := v.applyUpdateRRs(pubkey, label, []dns.RR{aRecord(ownName, "192.168.1.50")})
+	if refused {
+		t.Fatal("applyUpdateRRs() refused an in-namespace update")
+	}
+	if !applied {
+		t.Fatal("applyUpdateRRs() applied = false, want true")
+	}
+
+	if got := v.cache.Get(pubkey).LANEndpoint; got != "192.168.1.50:51820" {
+		t.Errorf("LANEndpoint = %q, want %q", got, "192.168.1.50:51820")
+	}
+}
+
+func TestApplyUpdateRRsDeleteClearsLANEndpoint(t *testing.T) {
+	v := Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	pubkey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	label, err := pubkeyToDnsLabel(pubkey)
+	if err != nil {
+		t.Fatalf("pubkeyToDnsLabel: %v", err)
+	}
+	v.cache.Set(pubkey, &PeerInfo{PubKey: pubkey, WgIP: "100.64.0.5", LANEndpoint: "192.168.1.50:51820"})
+
+	ownName := "lan." + label + ".valon.internal."
+	applied, refused := v.applyUpdateRRs(pubkey, label, []dns.RR{deleteRRset(ownName, dns.TypeA)})
+	if refused {
+		t.Fatal("applyUpdateRRs() refused an in-namespace delete")
+	}
+	if !applied {
+		t.Fatal("applyUpdateRRs() applied = false, want true")
+	}
+
+	if got := v.cache.Get(pubkey).LANEndpoint; got != "" {
+		t.Errorf("LANEndpoint = %q, want empty after delete", got)
+	}
+}
+
+// TestApplyUpdateRRsRemoveRRsetClearsLANEndpoint drives a real RFC 2136
+// delete-RRset through the wire (dns.Msg.RemoveRRset, TSIG-signed and
+// packed/unpacked exactly as signUpdate does for every other case here)
+// rather than the hand-built *dns.RR_Header deleteRRset uses: RemoveRRset
+// unpacks server-side as a *dns.A with Class ANY, not the bare header type
+// deleteRRset produces, and only the real wire path exercises the branch
+// that actually needs to recognize it.
+func TestApplyUpdateRRsRemoveRRsetClearsLANEndpoint(t *testing.T) {
+	v := Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	pubkey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	label, err := pubkeyToDnsLabel(pubkey)
+	if err != nil {
+		t.Fatalf("pubkeyToDnsLabel: %v", err)
+	}
+	v.cache.Set(pubkey, &PeerInfo{PubKey: pubkey, WgIP: "100.64.0.5", LANEndpoint: "192.168.1.50:51820"})
+
+	ownName := "lan." + label + ".valon.internal."
+	// A delete-RRset carries no rdata - like a real client, build the bare
+	// RR (no IP) and let RemoveRRset/packing produce the empty-rdata wire
+	// form, rather than passing a real IP that a delete wouldn't carry.
+	del := new(dns.Msg)
+	del.RemoveRRset([]dns.RR{aRecord(ownName, "")})
+
+	r := signUpdate(t, "valon.internal.", "mfrggzdf._valon.", testTsigSecret, del.Ns)
+
+	applied, refused := v.applyUpdateRRs(pubkey, label, r.Ns)
+	if refused {
+		t.Fatal("applyUpdateRRs() refused an in-namespace RemoveRRset")
+	}
+	if !applied {
+		t.Fatal("applyUpdateRRs() applied = false, want true")
+	}
+	if got := v.cache.Get(pubkey).LANEndpoint; got != "" {
+		t.Errorf("LANEndpoint = %q, want empty after RemoveRRset", got)
+	}
+}
+
+// TestApplyUpdateRRsRemoveClearsLANEndpoint mirrors
+// TestApplyUpdateRRsRemoveRRsetClearsLANEndpoint for dns.Msg.Remove (a
+// delete of one specific RR, class NONE), the other RFC 2136 delete form
+// RemoveName/RemoveRRset's class-ANY sibling doesn't cover.
+func TestApplyUpdateRRsRemoveClearsLANEndpoint(t *testing.T) {
+	v := Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	pubkey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	label, err := pubkeyToDnsLabel(pubkey)
+	if err != nil {
+		t.Fatalf("pubkeyToDnsLabel: %v", err)
+	}
+	v.cache.Set(pubkey, &PeerInfo{PubKey: pubkey, WgIP: "100.64.0.5", LANEndpoint: "192.168.1.50:51820"})
+
+	ownName := "lan." + label + ".valon.internal."
+	del := new(dns.Msg)
+	del.Remove([]dns.RR{aRecord(ownName, "192.168.1.50")})
+
+	r := signUpdate(t, "valon.internal.", "mfrggzdf._valon.", testTsigSecret, del.Ns)
+
+	applied, refused := v.applyUpdateRRs(pubkey, label, r.Ns)
+	if refused {
+		t.Fatal("applyUpdateRRs() refused an in-namespace Remove")
+	}
+	if !applied {
+		t.Fatal("applyUpdateRRs() applied = false, want true")
+	}
+	if got := v.cache.Get(pubkey).LANEndpoint; got != "" {
+		t.Errorf("LANEndpoint = %q, want empty after Remove", got)
+	}
+}
+
+func TestApplyUpdateRRsRefusesOutOfNamespace(t *testing.T) {
+	v := Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+	pubkey := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	label, err := pubkeyToDnsLabel(pubkey)
+	if err != nil {
+		t.Fatalf("pubkeyToDnsLabel: %v", err)
+	}
+	v.cache.Set(pubkey, &PeerInfo{PubKey: pubkey, WgIP: "100.64.0.5"})
+
+	otherLabel, err := pubkeyToDnsLabel(base64.StdEncoding.EncodeToString(bytesOf(1)))
+	if err != nil {
+		t.Fatalf("pubkeyToDnsLabel: %v", err)
+	}
+	otherName := "lan." + otherLabel + ".valon.internal."
+
+	applied, refused := v.applyUpdateRRs(pubkey, label, []dns.RR{aRecord(otherName, "192.168.1.50")})
+	if !refused {
+		t.Fatal("applyUpdateRRs() refused = false, want true for an out-of-namespace RR")
+	}
+	if applied {
+		t.Fatal("applyUpdateRRs() applied = true, want false when refused")
+	}
+	if got := v.cache.Get(pubkey).LANEndpoint; got != "" {
+		t.Errorf("LANEndpoint = %q, want untouched (empty)", got)
+	}
+}
+
+func TestHandleUpdateWrongZone(t *testing.T) {
+	v := &Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+
+	r := signUpdate(t, "other.zone.", "mfrggzdf._valon.", testTsigSecret, nil)
+	w := &testResponseWriter{}
+
+	if rc, err := v.handleUpdate(w, r); err != nil || rc != dns.RcodeNotZone {
+		t.Fatalf("handleUpdate() = (%d, %v), want (%d, nil)", rc, err, dns.RcodeNotZone)
+	}
+	if w.msg.Rcode != dns.RcodeNotZone {
+		t.Errorf("reply Rcode = %d, want %d", w.msg.Rcode, dns.RcodeNotZone)
+	}
+}
+
+func TestHandleUpdateRequiresTsig(t *testing.T) {
+	v := &Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+
+	r := new(dns.Msg)
+	r.SetUpdate("valon.internal.")
+	w := &testResponseWriter{}
+
+	if rc, err := v.handleUpdate(w, r); err != nil || rc != dns.RcodeRefused {
+		t.Fatalf("handleUpdate() = (%d, %v), want (%d, nil)", rc, err, dns.RcodeRefused)
+	}
+}
+
+func TestHandleUpdateWithoutEtcdBackendIsRefused(t *testing.T) {
+	// v.etcdClient is nil (no etcd backend configured), so even a
+	// well-formed, correctly TSIG-signed UPDATE can't be authenticated -
+	// there is nowhere to look up the shared secret from.
+	v := &Valon{Zone: "valon.internal.", cache: NewPeerCache()}
+
+	r := signUpdate(t, "valon.internal.", "mfrggzdf._valon.", testTsigSecret, nil)
+	w := &testResponseWriter{}
+
+	if rc, err := v.handleUpdate(w, r); err != nil || rc != dns.RcodeRefused {
+		t.Fatalf("handleUpdate() = (%d, %v), want (%d, nil)", rc, err, dns.RcodeRefused)
+	}
+}
+
+// bytesOf returns a 32-byte slice filled with b, for building a second,
+// distinct test pubkey.
+func bytesOf(b byte) []byte {
+	buf := make([]byte, 32)
+	for i := range buf {
+		buf[i] = b
+	}
+	return buf
+}
@@ -9,13 +9,12 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"strings"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/pabotesu/valon/coredns-plugin/valon/wgcfg"
 	clientv3 "go.etcd.io/etcd/client/v3"
 	"golang.zx2c4.com/wireguard/wgctrl"
-	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
 // Valon is the main plugin structure.
@@ -29,13 +28,58 @@ type Valon struct {
 	WgPollInterval   time.Duration // WireGuard polling interval (default: 1s)
 	EtcdSyncInterval time.Duration // etcd sync interval (default: 10s)
 
+	// LazyPeers enables lazy peer materialization: peers are only installed
+	// into the kernel WireGuard device while they are actively communicating.
+	LazyPeers         bool          // enable lazy peer materialization (default: false)
+	LazyIdleThreshold time.Duration // idle duration before an unused peer is deprogrammed (default: 5m)
+
+	// DDNSAuthMode selects how /api/endpoint requests are authorized:
+	// "ip" (source IP match, the original behavior), "signature" (Ed25519
+	// request signing, roam-safe), or "both" (accept either). Default: "ip".
+	DDNSAuthMode string
+
+	// RelayStuckThreshold is how long a peer may go without a successful
+	// handshake, despite having endpoint info on file, before it is
+	// considered stuck and rerouted through a relay peer. Default: 2m.
+	RelayStuckThreshold time.Duration
+
+	// Backend selects the PeerStore implementation: "etcd" (default) or
+	// "file". BackendPath is the JSON file path when Backend is "file";
+	// it is ignored otherwise.
+	Backend     string
+	BackendPath string
+
+	// NetmapURL, if set, switches peer-change distribution from a direct
+	// store watch (startPeerWatch) to a long-poll client against a
+	// valonctl netmap coordinator at this URL (e.g.
+	// "http://coordinator:8080/netmap") - see netmap.go. This lets
+	// multiple CoreDNS instances share one coalesced upstream view
+	// instead of each holding its own watch.
+	NetmapURL string
+
+	// DNSSECZSKPath, if set, enables online DNSSEC signing of answers under
+	// Zone using the Ed25519 or ECDSAP256SHA256 private key at that path
+	// (PEM-encoded PKCS#8). DNSSECKSKPath optionally names a separate
+	// key-signing key; if empty, the ZSK itself is published with the SEP
+	// bit set and signs the DNSKEY RRset.
+	DNSSECZSKPath string
+	DNSSECKSKPath string
+
 	// Zone
 	Zone string // DNS zone (e.g., "valon.internal.")
 
 	// Runtime
-	etcdClient *clientv3.Client // etcd client
+	etcdClient *clientv3.Client // etcd client; nil when Backend is not "etcd"
+	store      PeerStore        // durable peer record store, see store.go
 	cache      *PeerCache       // in-memory peer cache
 	stopCh     chan struct{}    // stop signal for background goroutines
+	selfWgIP   string           // this node's own WireGuard IP, set by registerSelf
+	nonces     *nonceCache      // replay protection for signed DDNS requests
+	signer     *dnssecSigner    // nil unless DNSSECZSKPath is configured, see dnssec.go
+
+	// storeLoaded is set once the initial loadFromEtcd call in Init has run
+	// to completion (whether or not it succeeded), for Ready to check.
+	storeLoaded bool
 }
 
 // Name returns the plugin name.
@@ -45,42 +89,88 @@ func (v Valon) Name() string {
 
 // Init initializes the VALON plugin.
 func (v *Valon) Init() error {
+	registerMetrics()
+
 	log.Printf("[valon] Initializing VALON plugin")
 	log.Printf("[valon] Zone: %s", v.Zone)
 	log.Printf("[valon] etcd endpoints: %v", v.EtcdEndpoints)
 	log.Printf("[valon] WireGuard interface: %s", v.WgInterface)
 	log.Printf("[valon] DDNS listen: %s", v.DdnsListen)
 
-	// Initialize etcd client
-	cli, err := clientv3.New(clientv3.Config{
-		Endpoints:   v.EtcdEndpoints,
-		DialTimeout: 5 * time.Second,
-	})
-	if err != nil {
-		log.Printf("[valon] Failed to connect to etcd: %v", err)
-		return err
+	if v.Backend == "" {
+		v.Backend = "etcd"
 	}
-	v.etcdClient = cli
+	log.Printf("[valon] Backend: %s", v.Backend)
 
-	// Test etcd connection
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-	_, err = cli.Get(ctx, "/valon/health")
-	if err != nil {
-		log.Printf("[valon] Warning: etcd connection test failed: %v", err)
-		// Don't fail initialization - etcd might be empty
-	} else {
-		log.Printf("[valon] etcd connection successful")
+	// EtcdSyncInterval is needed below to size EtcdStore's watch-fallback
+	// poll cadence, so its default is set here rather than alongside the
+	// other defaults further down.
+	if v.EtcdSyncInterval == 0 {
+		v.EtcdSyncInterval = 10 * time.Second
+	}
+
+	switch v.Backend {
+	case "etcd":
+		// Initialize etcd client
+		cli, err := clientv3.New(clientv3.Config{
+			Endpoints:   v.EtcdEndpoints,
+			DialTimeout: 5 * time.Second,
+		})
+		if err != nil {
+			log.Printf("[valon] Failed to connect to etcd: %v", err)
+			return err
+		}
+		v.etcdClient = cli
+
+		// Test etcd connection
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err = cli.Get(ctx, "/valon/health")
+		cancel()
+		if err != nil {
+			log.Printf("[valon] Warning: etcd connection test failed: %v", err)
+			// Don't fail initialization - etcd might be empty
+		} else {
+			log.Printf("[valon] etcd connection successful")
+		}
+
+		v.store = NewEtcdStore(cli, v.EtcdSyncInterval)
+
+	case "file":
+		if v.BackendPath == "" {
+			return fmt.Errorf("backend file requires a path, e.g. `backend file /var/lib/valon/peers.json`")
+		}
+		fileStore, err := NewFileStore(v.BackendPath)
+		if err != nil {
+			return fmt.Errorf("failed to open file backend %s: %w", v.BackendPath, err)
+		}
+		v.store = fileStore
+		log.Printf("[valon] Using file backend at %s; alias, identity, and relay-marker features require the etcd backend and are disabled", v.BackendPath)
+
+	default:
+		return fmt.Errorf("unknown backend %q: must be etcd or file", v.Backend)
 	}
 
 	// Initialize memory cache
 	v.cache = NewPeerCache()
 	log.Printf("[valon] Memory cache initialized")
 
-	// Load initial data from etcd
+	// Initialize replay-protection cache for signature-based DDNS auth
+	v.nonces = newNonceCache()
+
+	if v.DNSSECZSKPath != "" {
+		signer, err := newDNSSECSigner(v.Zone, v.DNSSECZSKPath, v.DNSSECKSKPath)
+		if err != nil {
+			return fmt.Errorf("failed to initialize DNSSEC signer: %w", err)
+		}
+		v.signer = signer
+		log.Printf("[valon] DNSSEC signing enabled for zone %s (algorithm: %d)", v.Zone, signer.zsk.Algorithm)
+	}
+
+	// Load initial data from the peer store
 	if err := v.loadFromEtcd(); err != nil {
-		log.Printf("[valon] Warning: failed to load from etcd: %v", err)
+		log.Printf("[valon] Warning: failed to load from store: %v", err)
 	}
+	v.storeLoaded = true
 
 	// Restore WireGuard peers from etcd (for restart resilience)
 	if err := v.restoreWireGuardPeers(); err != nil {
@@ -98,12 +188,22 @@ func (v *Valon) Init() error {
 	if v.WgPollInterval == 0 {
 		v.WgPollInterval = 1 * time.Second
 	}
-	if v.EtcdSyncInterval == 0 {
-		v.EtcdSyncInterval = 10 * time.Second
+	if v.LazyPeers && v.LazyIdleThreshold == 0 {
+		v.LazyIdleThreshold = 5 * time.Minute
+	}
+	if v.DDNSAuthMode == "" {
+		v.DDNSAuthMode = "ip"
+	}
+	if v.RelayStuckThreshold == 0 {
+		v.RelayStuckThreshold = 2 * time.Minute
 	}
 
 	log.Printf("[valon] WireGuard poll interval: %v", v.WgPollInterval)
 	log.Printf("[valon] etcd sync interval: %v", v.EtcdSyncInterval)
+	log.Printf("[valon] DDNS auth mode: %s", v.DDNSAuthMode)
+	if v.LazyPeers {
+		log.Printf("[valon] Lazy peer materialization enabled (idle threshold: %v)", v.LazyIdleThreshold)
+	}
 
 	// Initialize stop channel
 	v.stopCh = make(chan struct{})
@@ -111,6 +211,16 @@ func (v *Valon) Init() error {
 	// Start background monitors
 	go v.startWgMonitor()
 	go v.startEtcdSync()
+	if v.NetmapURL != "" {
+		log.Printf("[valon] Distributing peer changes via netmap coordinator %s instead of a direct store watch", v.NetmapURL)
+		go v.startNetmapClient()
+	} else {
+		go v.startPeerWatch()
+	}
+	go v.startRelaySupervisor()
+	if v.LazyPeers {
+		go v.startLazyPeerSupervisor()
+	}
 
 	// Start DDNS HTTP server
 	v.startDDNSServer()
@@ -119,92 +229,51 @@ func (v *Valon) Init() error {
 	return nil
 }
 
-// Ready implements the ready.Readiness interface.
+// Ready implements the ready.Readiness interface. It reports true only once
+// the WireGuard interface is up, the peer store is reachable, and the
+// initial peer load has completed - the three things a plugin instance
+// needs before it can usefully answer DNS-SD queries or accept DDNS updates.
 func (v Valon) Ready() bool {
-	// TODO: Check etcd connection, WireGuard interface, etc.
-	return true
+	if _, err := net.InterfaceByName(v.WgInterface); err != nil {
+		return false
+	}
+
+	if v.etcdClient != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := v.etcdClient.Get(ctx, "/valon/health")
+		cancel()
+		if err != nil {
+			return false
+		}
+	}
+
+	return v.storeLoaded
 }
 
-// loadFromEtcd loads all peer data from etcd into memory cache.
+// loadFromEtcd loads all peer data from the configured PeerStore into the
+// in-memory cache on startup. The name predates the PeerStore abstraction
+// (see store.go); it behaves the same regardless of which backend v.store
+// wraps.
 func (v *Valon) loadFromEtcd() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Get all keys under /valon/peers/
-	resp, err := v.etcdClient.Get(ctx, "/valon/peers/", clientv3.WithPrefix())
+	peers, err := v.store.List(ctx)
 	if err != nil {
-		return fmt.Errorf("etcd get failed: %w", err)
+		return fmt.Errorf("failed to list peers: %w", err)
 	}
 
-	if len(resp.Kvs) == 0 {
-		log.Printf("[valon] No peers found in etcd")
+	if len(peers) == 0 {
+		log.Printf("[valon] No peers found in store")
 		return nil
 	}
 
-	// Parse keys and group by pubkey
-	peersByPubkey := make(map[string]*PeerInfo)
-
-	for _, kv := range resp.Kvs {
-		key := string(kv.Key)
-		value := string(kv.Value)
-
-		// Parse key: /valon/peers/<pubkey>/wg_ip or /valon/peers/<pubkey>/endpoints/lan
-		// Note: pubkey may contain "/" characters in base64 encoding
-		relKey := strings.TrimPrefix(key, "/valon/peers/")
-
-		// Find pubkey by looking for known field patterns
-		// Known fields: wg_ip, alias, endpoints/
-		var pubkey, fieldPath string
-
-		if idx := strings.Index(relKey, "/wg_ip"); idx != -1 {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else if idx := strings.Index(relKey, "/alias"); idx != -1 {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else if idx := strings.Index(relKey, "/endpoints/"); idx != -1 {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else {
-			continue
-		}
-
-		if peersByPubkey[pubkey] == nil {
-			peersByPubkey[pubkey] = &PeerInfo{
-				PubKey: pubkey, // Set pubkey from etcd key
-			}
-		}
-
-		// Parse field path (e.g., "wg_ip" or "endpoints/lan")
-		fieldParts := strings.Split(fieldPath, "/")
-		if len(fieldParts) == 0 {
-			continue
-		}
-
-		switch fieldParts[0] {
-		case "wg_ip":
-			peersByPubkey[pubkey].WgIP = value
-		case "endpoints":
-			if len(fieldParts) >= 2 {
-				endpointType := fieldParts[1]
-				if endpointType == "lan" {
-					peersByPubkey[pubkey].LANEndpoint = value
-				} else if endpointType == "nated" {
-					peersByPubkey[pubkey].NATEndpoint = value
-				}
-			}
-		}
-	}
-
-	// Load into cache using pubkey as key
-	loaded := 0
-	for pubkey, peer := range peersByPubkey {
-		log.Printf("[valon] Loading peer into cache: pubkey=%s, wg_ip=%s", pubkey[:min(len(pubkey), 20)]+"...", peer.WgIP)
-		v.cache.Set(pubkey, peer)
-		loaded++
+	for _, peer := range peers {
+		log.Printf("[valon] Loading peer into cache: pubkey=%s, wg_ip=%s", peer.PubKey[:min(len(peer.PubKey), 20)]+"...", peer.WgIP)
+		v.cache.Set(peer.PubKey, peer)
 	}
 
-	log.Printf("[valon] Loaded %d peers from etcd into cache", loaded)
+	log.Printf("[valon] Loaded %d peers from store into cache", len(peers))
 	return nil
 }
 
@@ -243,6 +312,7 @@ func (v *Valon) registerSelf() error {
 	}
 
 	v.cache.Set(pubkey, selfInfo)
+	v.selfWgIP = wgIP
 	log.Printf("[valon] Registered self: pubkey=%s, wgIP=%s", pubkey, wgIP)
 
 	return nil
@@ -292,6 +362,11 @@ func (v *Valon) getOwnWireGuardIP() (string, error) {
 // restoreWireGuardPeers restores all peers from etcd to WireGuard interface.
 // This is called on plugin initialization to recover from restarts.
 func (v *Valon) restoreWireGuardPeers() error {
+	if v.LazyPeers {
+		log.Printf("[valon] Lazy peer materialization enabled, skipping eager WireGuard peer restore")
+		return nil
+	}
+
 	log.Printf("[valon] Restoring WireGuard peers from etcd...")
 
 	// Get all peers from cache (already loaded from etcd)
@@ -308,69 +383,43 @@ func (v *Valon) restoreWireGuardPeers() error {
 	}
 	defer wgClient.Close()
 
-	// Get current WireGuard device state
-	device, err := wgClient.Device(v.WgInterface)
-	if err != nil {
-		return fmt.Errorf("failed to get WireGuard device: %w", err)
-	}
-
-	// Build map of existing peers
-	existingPeers := make(map[string]bool)
-	for _, peer := range device.Peers {
-		pubkeyStr := base64.StdEncoding.EncodeToString(peer.PublicKey[:])
-		existingPeers[pubkeyStr] = true
-	}
-
-	// Add missing peers to WireGuard
-	restored := 0
-	skipped := 0
+	// Build the desired peer set and hand the add/remove diffing to
+	// wgcfg.ReconcileDevice instead of open-coding it here.
+	want := wgcfg.DeviceConfig{Peers: make([]wgcfg.PeerConfig, 0, len(peers))}
 	for _, peer := range peers {
-		// Skip if peer already exists in WireGuard
-		if existingPeers[peer.PubKey] {
-			skipped++
-			continue
-		}
-
-		// Parse WireGuard IP
-		_, ipNet, err := net.ParseCIDR(peer.WgIP + "/32")
-		if err != nil {
-			log.Printf("[valon] Warning: invalid WgIP for peer %s: %v", peer.PubKey, err)
-			continue
-		}
-
-		// Decode public key
-		pubkeyBytes, err := base64.StdEncoding.DecodeString(peer.PubKey)
+		pubkey, err := wgcfg.ParseNodePublicBase64(peer.PubKey)
 		if err != nil {
 			log.Printf("[valon] Warning: invalid pubkey for peer %s: %v", peer.PubKey, err)
 			continue
 		}
 
-		pubkey, err := wgtypes.NewKey(pubkeyBytes)
+		_, ipNet, err := net.ParseCIDR(peer.WgIP + "/32")
 		if err != nil {
-			log.Printf("[valon] Warning: failed to create key for peer %s: %v", peer.PubKey, err)
+			log.Printf("[valon] Warning: invalid WgIP for peer %s: %v", peer.PubKey, err)
 			continue
 		}
 
-		// Configure peer
-		peerConfig := wgtypes.PeerConfig{
-			PublicKey:  pubkey,
-			AllowedIPs: []net.IPNet{*ipNet},
-		}
+		peerCfg := wgcfg.PeerConfig{PublicKey: pubkey, AllowedIPs: []net.IPNet{*ipNet}}
 
-		// Apply configuration
-		config := wgtypes.Config{
-			Peers: []wgtypes.PeerConfig{peerConfig},
+		// Static peers have no DDNS-discovered endpoint to wait for; apply
+		// their pinned Endpoint immediately so they're reachable on restart.
+		if peer.IsStatic && peer.Endpoint != "" {
+			endpointAddr, err := net.ResolveUDPAddr("udp", peer.Endpoint)
+			if err != nil {
+				log.Printf("[valon] Warning: invalid static endpoint for peer %s: %v", peer.PubKey, err)
+			} else {
+				peerCfg.Endpoint = endpointAddr
+			}
 		}
 
-		if err := wgClient.ConfigureDevice(v.WgInterface, config); err != nil {
-			log.Printf("[valon] Warning: failed to restore peer %s: %v", peer.PubKey, err)
-			continue
-		}
+		want.Peers = append(want.Peers, peerCfg)
+	}
 
-		restored++
-		log.Printf("[valon] Restored peer: %s (IP: %s)", peer.PubKey[:16]+"...", peer.WgIP)
+	added, removed, unchanged, err := wgcfg.ReconcileDevice(wgClient, v.WgInterface, want)
+	if err != nil {
+		return fmt.Errorf("failed to reconcile WireGuard peers: %w", err)
 	}
 
-	log.Printf("[valon] WireGuard peer restoration complete: %d restored, %d already existed", restored, skipped)
+	log.Printf("[valon] WireGuard peer restoration complete: %d added, %d removed, %d already up to date", added, removed, unchanged)
 	return nil
 }
@@ -2,14 +2,11 @@ package valon
 
 import (
 	"context"
-	"encoding/base64"
-	"fmt"
 	"log"
 	"net"
-	"strings"
 	"time"
 
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"github.com/pabotesu/valon/coredns-plugin/valon/wgcfg"
 	"golang.zx2c4.com/wireguard/wgctrl"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
@@ -35,6 +32,16 @@ func (v *Valon) startWgMonitor() {
 }
 
 // pollWireGuard queries WireGuard interface using wgctrl and updates cache.
+//
+// Note: when LazyPeers is enabled, this only observes handshakes for peers
+// already materialized into the device (device.Peers). Detecting inbound
+// handshake attempts for peers that have never been installed, or that
+// evictIdlePeers has since deprogrammed, would require a raw AF_PACKET/BPF
+// listener on the wg interface; that signal is not implemented here, so
+// materialization is currently driven by outbound DNS resolution (see
+// handleA) or by this poll rediscovering a peer already live in the device
+// (see the loadPeerFromStore branch of processPeer below), not by inbound
+// handshakes. See materializePeer's doc comment in lazy_peers.go.
 func (v *Valon) pollWireGuard() {
 	client, err := wgctrl.New()
 	if err != nil {
@@ -53,12 +60,14 @@ func (v *Valon) pollWireGuard() {
 	for _, peer := range device.Peers {
 		v.processPeer(&peer)
 	}
+
+	peersTotal.Set(float64(v.cache.Count()))
 }
 
 // processPeer processes a single WireGuard peer and updates cache.
 func (v *Valon) processPeer(peer *wgtypes.Peer) {
 	// Convert public key to Base64 (standard WireGuard format)
-	pubkey := base64.StdEncoding.EncodeToString(peer.PublicKey[:])
+	pubkey := wgcfg.NodePublicFromKey(peer.PublicKey).Base64()
 
 	// Extract endpoint (NAT endpoint observed by WireGuard)
 	var endpoint string
@@ -73,13 +82,22 @@ func (v *Valon) processPeer(peer *wgtypes.Peer) {
 	existing := v.cache.Get(pubkey)
 	if existing == nil {
 		// New peer detected but not in cache
-		// Try to load from etcd (in case it was added via valonctl while CoreDNS was running)
-		if v.loadPeerFromEtcd(pubkey, wgIP) {
-			log.Printf("[valon] Loaded peer from etcd into cache: %s (wgIP: %s)", pubkey[:16]+"...", wgIP)
+		// Try to load from the store (in case it was added via valonctl while CoreDNS was running)
+		if v.loadPeerFromStore(pubkey, wgIP) {
+			log.Printf("[valon] Loaded peer from store into cache: %s (wgIP: %s)", pubkey[:16]+"...", wgIP)
+			if v.LazyPeers {
+				// The peer is already live in the device - wgctrl just
+				// reported it - so re-materialize it to bring the cache's
+				// Installed/LastActivity bookkeeping in line rather than
+				// leaving it as if this were a cold cache entry.
+				if err := v.materializePeer(pubkey); err != nil {
+					log.Printf("[valon] Failed to materialize rediscovered peer %s: %v", pubkey[:16]+"...", err)
+				}
+			}
 			// Now update with NAT endpoint
 			existing = v.cache.Get(pubkey)
 		} else {
-			// Not in etcd either - awaiting DDNS registration
+			// Not in the store either - awaiting DDNS registration
 			if peer.LastHandshakeTime.After(time.Now().Add(-30 * time.Second)) {
 				log.Printf("[valon] New peer detected: %s (wgIP: %s, endpoint: %s) - not in cache, awaiting DDNS registration",
 					pubkey[:16]+"...", wgIP, endpoint)
@@ -96,51 +114,47 @@ func (v *Valon) processPeer(peer *wgtypes.Peer) {
 		}
 		p.LastHandshake = peer.LastHandshakeTime
 		p.UpdatedAt = time.Now()
+		if v.LazyPeers {
+			// The peer showed up in `wg show`, so it is materialized by definition.
+			p.Installed = true
+		}
 
-		// Update NAT endpoint from wg observation
-		if endpoint != "" && p.NATEndpoint != endpoint {
+		// Update NAT endpoint from wg observation, unless this is a static
+		// peer: its endpoint is pinned by the operator, not discovered.
+		if !p.IsStatic && endpoint != "" && p.NATEndpoint != endpoint {
 			p.NATEndpoint = endpoint
 			p.dirty = true
 		}
 	})
+
+	alias := ""
+	if updated := v.cache.Get(pubkey); updated != nil {
+		alias = updated.Alias
+	}
+	peerHandshakeAge.WithLabelValues(pubkey, alias).Set(time.Since(peer.LastHandshakeTime).Seconds())
+	peerRxBytes.WithLabelValues(pubkey, alias).Set(float64(peer.ReceiveBytes))
+	peerTxBytes.WithLabelValues(pubkey, alias).Set(float64(peer.TransmitBytes))
 }
 
-// loadPeerFromEtcd attempts to load a peer from etcd and add to cache.
-// Returns true if peer was found and loaded, false otherwise.
-func (v *Valon) loadPeerFromEtcd(pubkey, wgIP string) bool {
-	ctx := context.Background()
-	peerPrefix := fmt.Sprintf("/valon/peers/%s/", pubkey)
+// loadPeerFromStore attempts to load a peer from v.store and add it to the
+// cache. Returns true if the peer was found and loaded, false otherwise.
+func (v *Valon) loadPeerFromStore(pubkey, wgIP string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	resp, err := v.etcdClient.Get(ctx, peerPrefix, clientv3.WithPrefix())
+	peer, err := v.store.Get(ctx, pubkey)
 	if err != nil {
-		log.Printf("[valon] Failed to query etcd for peer %s: %v", pubkey[:16]+"...", err)
+		log.Printf("[valon] Failed to query store for peer %s: %v", pubkey[:16]+"...", err)
 		return false
 	}
-
-	if len(resp.Kvs) == 0 {
-		return false // Not in etcd
-	}
-
-	// Parse peer data from etcd keys
-	peer := &PeerInfo{
-		PubKey: pubkey,
-		WgIP:   wgIP,
+	if peer == nil {
+		return false // Not in the store
 	}
 
-	for _, kv := range resp.Kvs {
-		key := string(kv.Key)
-		value := string(kv.Value)
-
-		if strings.HasSuffix(key, "/wg_ip") {
-			peer.WgIP = value
-		} else if strings.HasSuffix(key, "/endpoints/lan") {
-			peer.LANEndpoint = value
-		} else if strings.HasSuffix(key, "/endpoints/nated") {
-			peer.NATEndpoint = value
-		}
+	if peer.WgIP == "" {
+		peer.WgIP = wgIP
 	}
 
-	// Add to cache
 	v.cache.Set(pubkey, peer)
 	return true
 }
@@ -0,0 +1,205 @@
+// Package wgcfg provides strongly-typed WireGuard node keys and a device
+// reconciliation helper for the valon CoreDNS plugin.
+//
+// The plugin otherwise juggles a pubkey as a bare string in at least three
+// different encodings - base64 (etcd, the DDNS API), base32 (DNS labels),
+// and raw [32]byte (wgtypes.Key, as returned by wgctrl) - with conversions
+// between them open-coded at each call site. NodePublic/NodePrivate give
+// those conversions a single, typed home, so passing a DNS label where a
+// pubkey is expected (or vice versa) is a compile error instead of a wrong
+// answer discovered at runtime.
+package wgcfg
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// base32Encoding matches the unpadded, lowercase Base32 DNS label encoding
+// used throughout the plugin (see dnsLabelToPubkey/pubkeyToDnsLabel).
+var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NodePublic is a WireGuard public key. The zero value is not a valid key;
+// use ParseNodePublicBase64, ParseNodePublicDNSLabel, or NodePublicFromKey.
+type NodePublic struct {
+	raw [32]byte
+}
+
+// NodePrivate is a WireGuard private key.
+type NodePrivate struct {
+	raw [32]byte
+}
+
+// ParseNodePublicBase64 parses a standard base64-encoded WireGuard public
+// key, the format etcd and the DDNS API use.
+func ParseNodePublicBase64(s string) (NodePublic, error) {
+	key, err := wgtypes.ParseKey(s)
+	if err != nil {
+		return NodePublic{}, fmt.Errorf("invalid base64 public key: %w", err)
+	}
+	return NodePublic{raw: [32]byte(key)}, nil
+}
+
+// ParseNodePublicDNSLabel parses a base32 DNS label (the left-most label of
+// a "<label>.valon.internal." query) back into a public key.
+func ParseNodePublicDNSLabel(label string) (NodePublic, error) {
+	decoded, err := base32Encoding.DecodeString(strings.ToUpper(label))
+	if err != nil {
+		return NodePublic{}, fmt.Errorf("invalid base32 label: %w", err)
+	}
+	if len(decoded) != 32 {
+		return NodePublic{}, fmt.Errorf("invalid label length: %d bytes (expected 32)", len(decoded))
+	}
+	var raw [32]byte
+	copy(raw[:], decoded)
+	return NodePublic{raw: raw}, nil
+}
+
+// NodePublicFromKey adapts a wgtypes.Key, as returned by wgctrl's Device
+// calls, to a NodePublic.
+func NodePublicFromKey(key wgtypes.Key) NodePublic {
+	return NodePublic{raw: [32]byte(key)}
+}
+
+// Base64 returns the standard base64 WireGuard key encoding.
+func (n NodePublic) Base64() string {
+	return base64.StdEncoding.EncodeToString(n.raw[:])
+}
+
+// DNSLabel returns the lowercase, unpadded base32 DNS label encoding.
+func (n NodePublic) DNSLabel() string {
+	return strings.ToLower(base32Encoding.EncodeToString(n.raw[:]))
+}
+
+// Raw returns the underlying 32 key bytes.
+func (n NodePublic) Raw() [32]byte {
+	return n.raw
+}
+
+// IsZero reports whether n is the zero value (no key set).
+func (n NodePublic) IsZero() bool {
+	return n.raw == [32]byte{}
+}
+
+// String implements fmt.Stringer with a truncated form suitable for logs,
+// matching the `pubkey[:16]+"..."` convention used elsewhere in the plugin.
+func (n NodePublic) String() string {
+	b := n.Base64()
+	if len(b) > 16 {
+		return b[:16] + "..."
+	}
+	return b
+}
+
+func (n NodePublic) key() wgtypes.Key {
+	return wgtypes.Key(n.raw)
+}
+
+// ParseNodePrivateBase64 parses a standard base64-encoded WireGuard private key.
+func ParseNodePrivateBase64(s string) (NodePrivate, error) {
+	key, err := wgtypes.ParseKey(s)
+	if err != nil {
+		return NodePrivate{}, fmt.Errorf("invalid base64 private key: %w", err)
+	}
+	return NodePrivate{raw: [32]byte(key)}, nil
+}
+
+// Public derives the public key matching this private key.
+func (p NodePrivate) Public() NodePublic {
+	return NodePublic{raw: [32]byte(wgtypes.Key(p.raw).PublicKey())}
+}
+
+// Raw returns the underlying 32 key bytes.
+func (p NodePrivate) Raw() [32]byte {
+	return p.raw
+}
+
+// PeerConfig is a typed mirror of wgtypes.PeerConfig describing a single
+// desired peer for ReconcileDevice: a public key, the AllowedIPs it should
+// route, and an optional Endpoint to pin.
+type PeerConfig struct {
+	PublicKey  NodePublic
+	AllowedIPs []net.IPNet
+	Endpoint   *net.UDPAddr
+}
+
+func (p PeerConfig) toPeerConfig(updateOnly bool) wgtypes.PeerConfig {
+	cfg := wgtypes.PeerConfig{
+		PublicKey:  p.PublicKey.key(),
+		Endpoint:   p.Endpoint,
+		UpdateOnly: updateOnly,
+	}
+	if len(p.AllowedIPs) > 0 {
+		cfg.AllowedIPs = p.AllowedIPs
+		cfg.ReplaceAllowedIPs = true
+	}
+	return cfg
+}
+
+// DeviceConfig describes the full desired peer set for a WireGuard device.
+type DeviceConfig struct {
+	Peers []PeerConfig
+}
+
+// DeviceBackend is the minimal wgctrl surface ReconcileDevice needs; a
+// *wgctrl.Client satisfies it directly.
+type DeviceBackend interface {
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// ReconcileDevice brings the WireGuard device named iface in line with
+// want in a single ConfigureDevice call: peers in want but not yet on the
+// device are added, and peers on the device but absent from want are
+// removed (via Remove: true). Peers already present in both are left
+// untouched and counted in unchanged.
+//
+// This replaces the open-coded peer diffing that used to live in
+// restoreWireGuardPeers, which is its only caller; startEtcdSync's periodic
+// sync does not reconcile the device.
+func ReconcileDevice(backend DeviceBackend, iface string, want DeviceConfig) (added, removed, unchanged int, err error) {
+	device, err := backend.Device(iface)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to get device %s: %w", iface, err)
+	}
+
+	existing := make(map[NodePublic]bool, len(device.Peers))
+	for _, p := range device.Peers {
+		existing[NodePublicFromKey(p.PublicKey)] = true
+	}
+
+	wanted := make(map[NodePublic]bool, len(want.Peers))
+	var peerConfigs []wgtypes.PeerConfig
+	for _, peer := range want.Peers {
+		wanted[peer.PublicKey] = true
+		if existing[peer.PublicKey] {
+			unchanged++
+			continue
+		}
+		peerConfigs = append(peerConfigs, peer.toPeerConfig(false))
+		added++
+	}
+
+	for _, p := range device.Peers {
+		pub := NodePublicFromKey(p.PublicKey)
+		if !wanted[pub] {
+			peerConfigs = append(peerConfigs, wgtypes.PeerConfig{PublicKey: p.PublicKey, Remove: true})
+			removed++
+		}
+	}
+
+	if len(peerConfigs) == 0 {
+		return added, removed, unchanged, nil
+	}
+
+	if err := backend.ConfigureDevice(iface, wgtypes.Config{Peers: peerConfigs}); err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to reconcile device %s: %w", iface, err)
+	}
+
+	return added, removed, unchanged, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pabotesu/valon/valonctl/pkg/identity"
+)
+
+var (
+	identityKeyPath string
+
+	identityCmd = &cobra.Command{
+		Use:   "identity",
+		Short: "Manage the Ed25519 identity key used for signed DDNS registration",
+		Long: `Commands for generating and inspecting the Ed25519 identity keypair a peer
+uses to sign /api/endpoint registration requests (DDNSAuthMode "signature" or "both").`,
+	}
+
+	identityGenerateCmd = &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a new identity keypair",
+		Long: `Generate a new Ed25519 identity keypair and save the private key to disk.
+Pass the printed public key to "valonctl peer add --identity-pub" on the Discovery
+Role so signed registration requests from this peer can be verified.`,
+		RunE: runIdentityGenerate,
+	}
+)
+
+func init() {
+	identityCmd.PersistentFlags().StringVar(&identityKeyPath, "key-path", identity.DefaultKeyPath, "path to the identity private key")
+	identityCmd.AddCommand(identityGenerateCmd)
+	rootCmd.AddCommand(identityCmd)
+}
+
+func runIdentityGenerate(cmd *cobra.Command, args []string) error {
+	key, err := identity.Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate identity key: %w", err)
+	}
+
+	if err := key.Save(identityKeyPath); err != nil {
+		return fmt.Errorf("failed to save identity key: %w", err)
+	}
+
+	fmt.Printf("Identity key saved to %s\n", identityKeyPath)
+	fmt.Printf("Public key (pass to `valonctl peer add --identity-pub`): %s\n", key.PublicBase64())
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pabotesu/valon/valonctl/pkg/client"
+	"github.com/pabotesu/valon/valonctl/pkg/netmap"
+)
+
+var (
+	netmapListen string
+
+	netmapCmd = &cobra.Command{
+		Use:   "netmap",
+		Short: "Serve the long-poll peer distribution channel",
+		Long: `Commands for running valonctl as a netmap coordinator: a long-poll
+GET /netmap?since=<version> endpoint that one or more valon CoreDNS
+instances can poll instead of each holding their own direct etcd watch
+(see the valon plugin's netmap_url Corefile directive).`,
+	}
+
+	netmapServeCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Run the netmap coordinator HTTP server",
+		Long: `Run the netmap coordinator: watch /valon/peers/ in etcd and serve
+GET /netmap?since=<version> with long-poll semantics, so callers learn about
+peer changes without each holding their own etcd watch.
+
+Press Ctrl+C to stop.`,
+		RunE: runNetmapServe,
+	}
+)
+
+func init() {
+	netmapServeCmd.Flags().StringVar(&netmapListen, "listen", "127.0.0.1:8585", "address the netmap coordinator listens on")
+	netmapCmd.AddCommand(netmapServeCmd)
+	rootCmd.AddCommand(netmapCmd)
+}
+
+func runNetmapServe(cmd *cobra.Command, args []string) error {
+	etcdClient, err := client.NewEtcdClient(&cfg.Etcd, &cfg.DDNS)
+	if err != nil {
+		return fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	defer etcdClient.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopping := make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		close(stopping)
+		cancel()
+	}()
+
+	coordinator := netmap.NewCoordinator(etcdClient)
+
+	runErrCh := make(chan error, 1)
+	go func() {
+		runErrCh <- coordinator.Run(ctx)
+	}()
+
+	server := &http.Server{
+		Addr:    netmapListen,
+		Handler: coordinator,
+	}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	fmt.Printf("Netmap coordinator listening on %s (Ctrl+C to stop)...\n", netmapListen)
+
+	serveErr := server.ListenAndServe()
+	cancel()
+	runErr := <-runErrCh
+
+	select {
+	case <-stopping:
+		// Shutdown was requested via signal; errors from the resulting
+		// cancellation race (e.g. "context canceled") aren't failures.
+		return nil
+	default:
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("netmap coordinator stopped: %w", runErr)
+	}
+	if serveErr != nil && serveErr != http.ErrServerClosed {
+		return fmt.Errorf("netmap coordinator HTTP server failed: %w", serveErr)
+	}
+	return nil
+}
@@ -3,17 +3,31 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/netip"
+	"strings"
 	"time"
 
+	"github.com/miekg/dns"
 	"github.com/spf13/cobra"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
 	"github.com/pabotesu/valon/valonctl/pkg/client"
+	"github.com/pabotesu/valon/valonctl/pkg/encoding"
 	"github.com/pabotesu/valon/valonctl/pkg/validation"
+	"github.com/pabotesu/valon/valonctl/pkg/wgcfg"
 )
 
+const discoveryEndpointPlaceholder = "<DISCOVERY_ROLE_LAN_IP:51820>"
+
 var (
-	addWgIP  string
-	addAlias string
+	addWgIP        string
+	addAlias       string
+	addIdentityPub string
+	addRole        string
+	addEndpoint    string
+	addStatic      bool
+	addTsig        bool
 
 	peerAddCmd = &cobra.Command{
 		Use:   "add <pubkey>",
@@ -22,7 +36,14 @@ var (
 This command:
 1. Validates the public key and alias
 2. Adds the peer to the WireGuard interface
-3. Registers the peer in etcd with alias mapping`,
+3. Registers the peer in etcd with alias mapping
+
+--static marks the peer as WireGuard-only: a cloud router, vendor
+appliance, or site-to-site link that does not run valonctl and so never
+registers an endpoint via DDNS. Pair it with --endpoint to pin the peer's
+address; the DDNS watch loop leaves a static peer's endpoint alone, and
+the WireGuard reconfigure path applies the pinned Endpoint directly
+instead of waiting for a discovered one.`,
 		Args: cobra.ExactArgs(1),
 		RunE: runPeerAdd,
 	}
@@ -31,6 +52,11 @@ This command:
 func init() {
 	peerAddCmd.Flags().StringVar(&addWgIP, "wg-ip", "", "WireGuard IP address for the peer (auto-allocated if not specified)")
 	peerAddCmd.Flags().StringVar(&addAlias, "alias", "", "User-friendly alias for the peer (required)")
+	peerAddCmd.Flags().StringVar(&addIdentityPub, "identity-pub", "", "Peer's Ed25519 identity public key (base64, from `valonctl identity generate`), enables signed DDNS registration")
+	peerAddCmd.Flags().StringVar(&addRole, "role", "", "Peer's declared role (e.g. \"relay\" for a valond relay server)")
+	peerAddCmd.Flags().StringVar(&addEndpoint, "endpoint", "", "Pinned endpoint (host:port) for a static peer")
+	peerAddCmd.Flags().BoolVar(&addStatic, "static", false, "Mark the peer as WireGuard-only: skip DDNS/NAT-endpoint discovery and use --endpoint directly")
+	peerAddCmd.Flags().BoolVar(&addTsig, "tsig", false, "Provision a TSIG shared secret so this peer can register its LAN endpoint via an authenticated DNS UPDATE instead of the DDNS HTTP API")
 	peerAddCmd.MarkFlagRequired("alias")
 
 	peerCmd.AddCommand(peerAddCmd)
@@ -39,11 +65,21 @@ func init() {
 func runPeerAdd(cmd *cobra.Command, args []string) error {
 	pubkey := args[0]
 
-	// Validate alias
-	if err := validation.ValidateAlias(addAlias); err != nil {
+	// Validate alias against this deployment's reserved-prefix/reserved-zone
+	// policy (cfg.Aliases, layered on validation.DefaultReservedPolicy).
+	if err := validation.ValidateAliasWithPolicy(addAlias, cfg.Aliases.ReservedPolicy()); err != nil {
 		return fmt.Errorf("invalid alias: %w", err)
 	}
 
+	if addStatic && addEndpoint == "" {
+		return fmt.Errorf("--static requires --endpoint")
+	}
+	if addEndpoint != "" {
+		if _, err := net.ResolveUDPAddr("udp", addEndpoint); err != nil {
+			return fmt.Errorf("invalid --endpoint %q: %w", addEndpoint, err)
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -66,7 +102,7 @@ func runPeerAdd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create WireGuard client
-	wgClient, err := client.NewWireGuardClient()
+	wgClient, err := client.NewWireGuardClient(cfg.WireGuard.Mode)
 	if err != nil {
 		return fmt.Errorf("failed to create WireGuard client: %w", err)
 	}
@@ -77,13 +113,32 @@ func runPeerAdd(cmd *cobra.Command, args []string) error {
 	if err := wgClient.AddPeer(cfg.WireGuard.Interface, pubkey, addWgIP); err != nil {
 		return fmt.Errorf("failed to add peer to WireGuard: %w", err)
 	}
+	if addEndpoint != "" {
+		if err := wgClient.SetPeerEndpoint(cfg.WireGuard.Interface, pubkey, addEndpoint); err != nil {
+			_ = wgClient.RemovePeer(cfg.WireGuard.Interface, pubkey)
+			return fmt.Errorf("failed to pin peer endpoint: %w", err)
+		}
+	}
 
 	// Register peer in etcd
 	fmt.Println("Registering peer in etcd...")
 	peerInfo := &client.PeerInfo{
-		Pubkey: pubkey,
-		IP:     addWgIP,
-		Alias:  addAlias,
+		Pubkey:      pubkey,
+		IP:          addWgIP,
+		Alias:       addAlias,
+		IdentityPub: addIdentityPub,
+		Role:        addRole,
+		Endpoint:    addEndpoint,
+		IsStatic:    addStatic,
+	}
+
+	var tsigSecret string
+	if addTsig {
+		tsigSecret, err = client.GenerateTsigSecret()
+		if err != nil {
+			return fmt.Errorf("failed to generate TSIG secret: %w", err)
+		}
+		peerInfo.TsigSecret = tsigSecret
 	}
 
 	if err := etcdClient.AddPeer(ctx, peerInfo); err != nil {
@@ -95,6 +150,25 @@ func runPeerAdd(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("✓ Successfully added peer %s (alias: %s, IP: %s)\n", pubkey, addAlias, addWgIP)
 
+	if tsigSecret != "" {
+		label, err := encoding.PubkeyToLabel(pubkey)
+		if err != nil {
+			fmt.Printf("Warning: failed to derive DNS label for TSIG key name: %v\n", err)
+		} else {
+			fmt.Println("\n=== TSIG Key for DNS UPDATE Registration ===")
+			fmt.Printf("Key name:   %s._valon.\n", label)
+			fmt.Printf("Algorithm:  %s\n", dns.HmacSHA256)
+			fmt.Printf("Secret:     %s\n", tsigSecret)
+			fmt.Println("Deliver this secret to the peer out-of-band; valonctl does not display it again.")
+			fmt.Println("==============================================")
+		}
+	}
+
+	if addStatic {
+		// Static peers don't run valonctl, so there's no wg0.conf to print.
+		return nil
+	}
+
 	// Generate WireGuard configuration file for the client
 	fmt.Println("\n=== WireGuard Configuration for Client ===")
 	if err := printClientConfig(wgClient, pubkey, addWgIP); err != nil {
@@ -105,6 +179,9 @@ func runPeerAdd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printClientConfig renders the wg0.conf a newly added client should install,
+// via pkg/wgcfg so config generation here, in showconf, and in peer import
+// all flow through the same round-trippable representation.
 func printClientConfig(wgClient *client.WireGuardClient, clientPubkey, clientIP string) error {
 	// Get Discovery Role's public key
 	discoveryPubkey, err := wgClient.GetPublicKey(cfg.WireGuard.Interface)
@@ -112,36 +189,64 @@ func printClientConfig(wgClient *client.WireGuardClient, clientPubkey, clientIP
 		return fmt.Errorf("failed to get Discovery public key: %w", err)
 	}
 
-	// Use endpoint from config, or provide placeholder
-	discoveryEndpoint := cfg.WireGuard.Endpoint
-	if discoveryEndpoint == "" {
-		discoveryEndpoint = "<DISCOVERY_ROLE_LAN_IP:51820>"
+	pubKey, err := wgtypes.ParseKey(discoveryPubkey)
+	if err != nil {
+		return fmt.Errorf("invalid Discovery public key: %w", err)
+	}
+
+	discoveryIP, err := netip.ParsePrefix(cfg.WireGuard.IP + "/32")
+	if err != nil {
+		return fmt.Errorf("invalid Discovery IP %q: %w", cfg.WireGuard.IP, err)
 	}
 
 	// Get network prefix from IP (assume /24 for simplicity, can be enhanced)
-	networkPrefix := "24"
+	clientPrefix, err := netip.ParsePrefix(clientIP + "/24")
+	if err != nil {
+		return fmt.Errorf("invalid client IP %q: %w", clientIP, err)
+	}
 
-	fmt.Printf(`
-Save this as /etc/wireguard/wg0.conf on the client:
+	peer := wgcfg.Peer{
+		PublicKey:           pubKey,
+		AllowedIPs:          []netip.Prefix{discoveryIP},
+		PersistentKeepalive: 25 * time.Second,
+	}
+
+	// Use endpoint from config if set; otherwise leave a placeholder the
+	// admin fills in once the Discovery Role has a public endpoint.
+	endpointLine := fmt.Sprintf("Endpoint = %s\n", discoveryEndpointPlaceholder)
+	if cfg.WireGuard.Endpoint != "" {
+		addr, err := net.ResolveUDPAddr("udp", cfg.WireGuard.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid Discovery endpoint %q: %w", cfg.WireGuard.Endpoint, err)
+		}
+		peer.Endpoint = addr
+		endpointLine = ""
+	}
 
-[Interface]
-Address = %s/%s
-PrivateKey = <INSERT_YOUR_PRIVATE_KEY_HERE>
-MTU = 1420
+	clientCfg := wgcfg.Config{
+		Addresses: []netip.Prefix{clientPrefix},
+		MTU:       1420,
+		Peers:     []wgcfg.Peer{peer},
+	}
 
-[Peer]
-# Discovery Role
-PublicKey = %s
-Endpoint = %s
-AllowedIPs = %s/32
-PersistentKeepalive = 25
+	data, err := clientCfg.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to render client config: %w", err)
+	}
+
+	rendered := strings.Replace(string(data), "[Interface]\n", "[Interface]\nPrivateKey = <INSERT_YOUR_PRIVATE_KEY_HERE>\n", 1)
+	rendered = strings.Replace(rendered, fmt.Sprintf("PublicKey = %s\n", discoveryPubkey), fmt.Sprintf("PublicKey = %s\n%s", discoveryPubkey, endpointLine), 1)
+
+	fmt.Printf(`
+Save this as /etc/wireguard/wg0.conf on the client:
 
+%s
 Then run on the client:
   1. Generate keys: wg genkey | tee privatekey | wg pubkey
   2. Edit /etc/wireguard/wg0.conf and insert your PrivateKey
   3. Start interface: sudo wg-quick up wg0
   4. Bootstrap: sudo valon-bootstrap
-`, clientIP, networkPrefix, discoveryPubkey, discoveryEndpoint, cfg.WireGuard.IP)
+`, rendered)
 
 	return nil
 }
@@ -17,7 +17,11 @@ var (
 		Use:   "export [output-file]",
 		Short: "Export peer information to JSON file",
 		Long: `Export all registered peers to a JSON file.
-This excludes dynamic fields (LAN/NAT endpoints) for static hosting purposes (e.g., Cloudflare Pages).
+This leaves out the separate LAN/NAT endpoint fields for static hosting
+purposes (e.g., Cloudflare Pages). The Endpoint field is exported for every
+peer: a static (WireGuard-only) peer's pinned Endpoint, which survives a
+round trip through "peer import", and for a dynamic peer with no pinned
+endpoint the LAN or NAT endpoint that the peer listing reports in its place.
 
 Example:
   valonctl peer export              # exports to ./peers.json
@@ -33,16 +37,18 @@ func init() {
 
 // ExportedPeer represents static peer information suitable for export
 type ExportedPeer struct {
-	Pubkey string `json:"pubkey"`
-	IP     string `json:"ip"`
-	Alias  string `json:"alias"`
+	Pubkey   string `json:"pubkey"`
+	IP       string `json:"ip"`
+	Alias    string `json:"alias"`
+	Endpoint string `json:"endpoint,omitempty"`
+	IsStatic bool   `json:"is_static,omitempty"`
 }
 
-func runPeerExp"peers.json" // default
+func runPeerExport(cmd *cobra.Command, args []string) error {
+	outputPath := "peers.json" // default
 	if len(args) > 0 {
 		outputPath = args[0]
-	} *cobra.Command, args []string) error {
-	outputPath := args[0]
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -68,9 +74,11 @@ func runPeerExp"peers.json" // default
 	exportData := make([]ExportedPeer, 0, len(peers))
 	for _, peer := range peers {
 		exportData = append(exportData, ExportedPeer{
-			Pubkey: peer.Pubkey,
-			IP:     peer.IP,
-			Alias:  peer.Alias,
+			Pubkey:   peer.Pubkey,
+			IP:       peer.IP,
+			Alias:    peer.Alias,
+			Endpoint: peer.Endpoint,
+			IsStatic: peer.IsStatic,
 		})
 	}
 
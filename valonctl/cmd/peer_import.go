@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pabotesu/valon/valonctl/pkg/client"
+	"github.com/pabotesu/valon/valonctl/pkg/wgcfg"
+)
+
+var (
+	importFrom   string
+	importDryRun bool
+	importPrune  bool
+
+	peerImportCmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Reconcile WireGuard + etcd peers against a peer list file",
+		Long: `Read a peer list (the inverse of "peer export") and reconcile the live
+WireGuard interface and etcd registrations so they match it: peers present
+in the file but not known locally are added, peers whose alias or IP
+changed are updated, and (with --prune) peers known locally but absent
+from the file are removed.
+
+--from json (the default) expects the same schema peer export produces.
+--from wg-quick accepts a wg-quick style INI instead; since wg-quick has
+no alias field, imported peers are given a placeholder alias derived from
+their public key - pass --from json with real aliases for anything beyond
+a one-off import.
+
+Use --dry-run to print the computed diff without changing anything. This
+is the intended entrypoint for GitOps workflows that check a peer list
+into a repo and want "valonctl peer import peers.json" to be the apply
+step.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runPeerImport,
+	}
+)
+
+func init() {
+	peerImportCmd.Flags().StringVar(&importFrom, "from", "json", "input format: json|wg-quick")
+	peerImportCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "print the diff without mutating WireGuard or etcd")
+	peerImportCmd.Flags().BoolVar(&importPrune, "prune", false, "remove peers known locally but absent from the file")
+
+	peerCmd.AddCommand(peerImportCmd)
+}
+
+func runPeerImport(cmd *cobra.Command, args []string) error {
+	inputPath := args[0]
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", inputPath, err)
+	}
+
+	var wanted []ExportedPeer
+	switch importFrom {
+	case "json":
+		if err := json.Unmarshal(data, &wanted); err != nil {
+			return fmt.Errorf("failed to parse %s as JSON: %w", inputPath, err)
+		}
+	case "wg-quick":
+		wanted, err = parseWgQuickPeers(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s as wg-quick: %w", inputPath, err)
+		}
+	default:
+		return fmt.Errorf("invalid --from %q: must be json or wg-quick", importFrom)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	etcdClient, err := client.NewEtcdClient(&cfg.Etcd, &cfg.DDNS)
+	if err != nil {
+		return fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	defer etcdClient.Close()
+
+	current, err := etcdClient.ListPeers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list peers: %w", err)
+	}
+
+	toAdd, toUpdate, toRemove := diffPeers(current, wanted)
+
+	if len(toAdd) == 0 && len(toUpdate) == 0 && (len(toRemove) == 0 || !importPrune) {
+		fmt.Println("Nothing to do: WireGuard and etcd already match the file")
+		return nil
+	}
+
+	printImportDiff(toAdd, toUpdate, toRemove)
+	if importDryRun {
+		return nil
+	}
+
+	wgClient, err := client.NewWireGuardClient(cfg.WireGuard.Mode)
+	if err != nil {
+		return fmt.Errorf("failed to create WireGuard client: %w", err)
+	}
+	defer wgClient.Close()
+
+	// Reconcile the WireGuard device in a single UpdatePeers call instead of
+	// one ConfigureDevice per peer, so a large import costs one netlink
+	// transaction rather than len(toAdd)+len(toUpdate)+len(toRemove).
+	desired := make([]*client.PeerInfo, 0, len(toAdd)+len(toUpdate))
+	for _, peer := range append(append([]ExportedPeer{}, toAdd...), toUpdate...) {
+		desired = append(desired, peerInfoFromExported(peer))
+	}
+
+	var removeKeys []string
+	if importPrune {
+		for _, peer := range toRemove {
+			removeKeys = append(removeKeys, peer.Pubkey)
+		}
+	}
+
+	if len(desired) > 0 || len(removeKeys) > 0 {
+		if err := wgClient.UpdatePeers(cfg.WireGuard.Interface, desired, client.UpdateOpts{Remove: removeKeys}); err != nil {
+			return fmt.Errorf("failed to reconcile WireGuard peers: %w", err)
+		}
+	}
+
+	for _, peer := range toAdd {
+		if err := etcdClient.AddPeer(ctx, peerInfoFromExported(peer)); err != nil {
+			// Roll back just this peer from the batch we just applied.
+			_ = wgClient.UpdatePeers(cfg.WireGuard.Interface, nil, client.UpdateOpts{Remove: []string{peer.Pubkey}})
+			return fmt.Errorf("failed to register peer %s in etcd: %w", peer.Alias, err)
+		}
+	}
+
+	for _, peer := range toUpdate {
+		// There is no in-place update on the peer API, so reconcile an
+		// update by dropping the old registration and adding the new one.
+		if err := etcdClient.RemovePeer(ctx, peer.Pubkey); err != nil {
+			return fmt.Errorf("failed to remove stale registration for %s: %w", peer.Alias, err)
+		}
+		if err := etcdClient.AddPeer(ctx, peerInfoFromExported(peer)); err != nil {
+			return fmt.Errorf("failed to update peer %s in etcd: %w", peer.Alias, err)
+		}
+	}
+
+	if importPrune {
+		for _, peer := range toRemove {
+			if err := etcdClient.RemovePeer(ctx, peer.Pubkey); err != nil {
+				return fmt.Errorf("failed to remove peer %s from etcd: %w", peer.Alias, err)
+			}
+		}
+	}
+
+	fmt.Println("✓ Reconcile complete")
+	return nil
+}
+
+// peerInfoFromExported converts an ExportedPeer into the client.PeerInfo
+// shape etcdClient.AddPeer and wgClient.UpdatePeers expect.
+func peerInfoFromExported(peer ExportedPeer) *client.PeerInfo {
+	return &client.PeerInfo{
+		Pubkey:   peer.Pubkey,
+		IP:       peer.IP,
+		Alias:    peer.Alias,
+		Endpoint: peer.Endpoint,
+		IsStatic: peer.IsStatic,
+	}
+}
+
+// diffPeers compares the live peer set against the wanted peer set by
+// pubkey, returning peers to add, peers whose alias, IP, endpoint, or static
+// flag changed, and peers present live but absent from the wanted set.
+func diffPeers(current []*client.PeerInfo, wanted []ExportedPeer) (toAdd, toUpdate, toRemove []ExportedPeer) {
+	currentByPubkey := make(map[string]*client.PeerInfo, len(current))
+	for _, peer := range current {
+		currentByPubkey[peer.Pubkey] = peer
+	}
+
+	wantedPubkeys := make(map[string]bool, len(wanted))
+	for _, peer := range wanted {
+		wantedPubkeys[peer.Pubkey] = true
+
+		existing, ok := currentByPubkey[peer.Pubkey]
+		if !ok {
+			toAdd = append(toAdd, peer)
+			continue
+		}
+		if existing.Alias != peer.Alias || existing.IP != peer.IP ||
+			existing.Endpoint != peer.Endpoint || existing.IsStatic != peer.IsStatic {
+			toUpdate = append(toUpdate, peer)
+		}
+	}
+
+	for _, peer := range current {
+		if !wantedPubkeys[peer.Pubkey] {
+			toRemove = append(toRemove, ExportedPeer{
+				Pubkey:   peer.Pubkey,
+				IP:       peer.IP,
+				Alias:    peer.Alias,
+				Endpoint: peer.Endpoint,
+				IsStatic: peer.IsStatic,
+			})
+		}
+	}
+
+	return toAdd, toUpdate, toRemove
+}
+
+func printImportDiff(toAdd, toUpdate, toRemove []ExportedPeer) {
+	fmt.Printf("Plan: %d to add, %d to update, %d to remove", len(toAdd), len(toUpdate), len(toRemove))
+	if len(toRemove) > 0 && !importDryRun && !importPrune {
+		fmt.Printf(" (removals skipped: pass --prune to apply them)")
+	}
+	fmt.Println()
+
+	for _, peer := range toAdd {
+		fmt.Printf("  + %s (%s, %s)\n", peer.Alias, peer.Pubkey, peer.IP)
+	}
+	for _, peer := range toUpdate {
+		fmt.Printf("  ~ %s (%s, %s)\n", peer.Alias, peer.Pubkey, peer.IP)
+	}
+	for _, peer := range toRemove {
+		fmt.Printf("  - %s (%s, %s)\n", peer.Alias, peer.Pubkey, peer.IP)
+	}
+}
+
+// parseWgQuickPeers extracts [Peer] stanzas from a wg-quick style INI via
+// pkg/wgcfg. wg-quick has no alias field, so each peer is given a
+// placeholder alias derived from its public key.
+func parseWgQuickPeers(data []byte) ([]ExportedPeer, error) {
+	wgConfig, err := wgcfg.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]ExportedPeer, 0, len(wgConfig.Peers))
+	for _, p := range wgConfig.Peers {
+		pubkey := p.PublicKey.String()
+		peer := ExportedPeer{Pubkey: pubkey, Alias: "imported-" + truncatePubkey(pubkey)}
+		if len(p.AllowedIPs) > 0 {
+			peer.IP = p.AllowedIPs[0].Addr().String()
+		}
+		peers = append(peers, peer)
+	}
+
+	return peers, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pabotesu/valon/valonctl/pkg/client"
+	"github.com/pabotesu/valon/valonctl/pkg/identity"
+)
+
+var (
+	registerPubKey      string
+	registerLANEndpoint string
+	registerAlias       string
+
+	peerRegisterCmd = &cobra.Command{
+		Use:   "register",
+		Short: "Register this peer's current endpoint with the DDNS API",
+		Long: `Register this peer's current LAN endpoint with the Discovery Role's DDNS API.
+
+Run this on the peer itself (not the Discovery Role) whenever its reachable
+address changes, e.g. after roaming to a new network. If ddns.auth_mode is
+"signature" or "both", the request is signed with the local identity key
+(see "valonctl identity generate") so it is accepted regardless of the
+peer's current source IP.`,
+		RunE: runPeerRegister,
+	}
+)
+
+func init() {
+	peerRegisterCmd.Flags().StringVar(&registerPubKey, "pubkey", "", "this peer's WireGuard public key (required)")
+	peerRegisterCmd.Flags().StringVar(&registerLANEndpoint, "lan-endpoint", "", "this peer's reachable endpoint, IP:PORT (required)")
+	peerRegisterCmd.Flags().StringVar(&registerAlias, "alias", "", "CNAME alias to register alongside the endpoint (optional)")
+	peerRegisterCmd.Flags().StringVar(&identityKeyPath, "identity-key-path", identity.DefaultKeyPath, "path to this peer's identity private key, used when ddns.auth_mode is signature or both")
+	peerRegisterCmd.MarkFlagRequired("pubkey")
+	peerRegisterCmd.MarkFlagRequired("lan-endpoint")
+
+	peerCmd.AddCommand(peerRegisterCmd)
+}
+
+func runPeerRegister(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ddnsClient := client.NewDDNSClient(cfg.DDNS.APIURL)
+	update := client.EndpointUpdate{
+		PubKey:      registerPubKey,
+		LANEndpoint: registerLANEndpoint,
+		Alias:       registerAlias,
+	}
+
+	switch cfg.DDNS.AuthMode {
+	case "signature", "both":
+		key, err := identity.Load(identityKeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load identity key (run `valonctl identity generate` first): %w", err)
+		}
+		if err := ddnsClient.RegisterEndpointSigned(ctx, update, key); err != nil {
+			return fmt.Errorf("failed to register endpoint: %w", err)
+		}
+	default:
+		if err := ddnsClient.RegisterEndpoint(ctx, update); err != nil {
+			return fmt.Errorf("failed to register endpoint: %w", err)
+		}
+	}
+
+	fmt.Printf("✓ Registered endpoint %s for %s\n", registerLANEndpoint, registerPubKey)
+	return nil
+}
@@ -62,7 +62,7 @@ func runPeerRemove(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create WireGuard client
-	wgClient, err := client.NewWireGuardClient()
+	wgClient, err := client.NewWireGuardClient(cfg.WireGuard.Mode)
 	if err != nil {
 		return fmt.Errorf("failed to create WireGuard client: %w", err)
 	}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pabotesu/valon/valonctl/pkg/client"
+	"github.com/pabotesu/valon/valonctl/pkg/wgcfg"
+)
+
+const defaultPersistentKeepalive = 25
+
+var (
+	showconfFormat string
+	showconfAsPeer bool
+
+	peerShowconfCmd = &cobra.Command{
+		Use:   "showconf [pubkey|alias]",
+		Short: "Render a WireGuard configuration for the Discovery Role or a peer",
+		Long: `Render the WireGuard configuration describing either the local Discovery
+Role node or a named peer (by pubkey or alias), in "wireguard", "json", or
+"yaml" format.
+
+By default it renders the selected node's own config: its [Interface]
+stanza plus a [Peer] stanza for every other known peer, the way its own
+wg0.conf would look. With --as-peer it instead renders a single [Peer]
+stanza describing how *another* interface should peer with the selected
+node - the same shape "peer add" has always printed ad hoc, now available
+on demand and in a scriptable format for CI / infra-as-code.
+
+If no target is given, the local Discovery Role node is used.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runPeerShowconf,
+	}
+)
+
+func init() {
+	peerShowconfCmd.Flags().StringVarP(&showconfFormat, "output", "o", "wireguard", "output format: wireguard|json|yaml")
+	peerShowconfCmd.Flags().BoolVar(&showconfAsPeer, "as-peer", false, "render a single [Peer] stanza for peering with the selected node, instead of its own full config")
+
+	peerCmd.AddCommand(peerShowconfCmd)
+}
+
+// interfaceConf and peerConf are the stable JSON/YAML schema for showconf,
+// so downstream tooling has a fixed shape to parse regardless of -o.
+type interfaceConf struct {
+	PublicKey string `json:"public_key" yaml:"public_key"`
+	Address   string `json:"address" yaml:"address"`
+}
+
+type peerConf struct {
+	PublicKey           string   `json:"public_key" yaml:"public_key"`
+	AllowedIPs          []string `json:"allowed_ips" yaml:"allowed_ips"`
+	Endpoint            string   `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	PersistentKeepalive int      `json:"persistent_keepalive,omitempty" yaml:"persistent_keepalive,omitempty"`
+}
+
+type showconfOutput struct {
+	Interface *interfaceConf `json:"interface,omitempty" yaml:"interface,omitempty"`
+	Peers     []peerConf     `json:"peers" yaml:"peers"`
+}
+
+func runPeerShowconf(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	etcdClient, err := client.NewEtcdClient(&cfg.Etcd, &cfg.DDNS)
+	if err != nil {
+		return fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	defer etcdClient.Close()
+
+	peers, err := etcdClient.ListPeers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list peers: %w", err)
+	}
+
+	target, rest, err := resolveShowconfTarget(peers, args)
+	if err != nil {
+		return err
+	}
+
+	var out showconfOutput
+	if showconfAsPeer {
+		out = showconfOutput{Peers: []peerConf{peerConfFor(target)}}
+	} else {
+		out = showconfOutput{
+			Interface: &interfaceConf{PublicKey: target.Pubkey, Address: target.IP},
+			Peers:     make([]peerConf, 0, len(rest)),
+		}
+		for _, p := range rest {
+			out.Peers = append(out.Peers, peerConfFor(p))
+		}
+	}
+
+	return printShowconf(out)
+}
+
+// resolveShowconfTarget picks the selected node (args[0] by pubkey/alias, or
+// the local Discovery Role if no argument was given) and returns it
+// alongside every other peer.
+func resolveShowconfTarget(peers []*client.PeerInfo, args []string) (*client.PeerInfo, []*client.PeerInfo, error) {
+	if len(args) == 0 {
+		wgClient, err := client.NewWireGuardClient(cfg.WireGuard.Mode)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create WireGuard client: %w", err)
+		}
+		defer wgClient.Close()
+
+		pubkey, err := wgClient.GetPublicKey(cfg.WireGuard.Interface)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get local public key: %w", err)
+		}
+
+		self := &client.PeerInfo{Pubkey: pubkey, IP: cfg.WireGuard.IP, LANEndpoint: cfg.WireGuard.Endpoint}
+		rest := make([]*client.PeerInfo, 0, len(peers))
+		for _, p := range peers {
+			if p.Pubkey != pubkey {
+				rest = append(rest, p)
+			}
+		}
+		return self, rest, nil
+	}
+
+	pubkeyOrAlias := args[0]
+	for i, p := range peers {
+		if p.Pubkey == pubkeyOrAlias || p.Alias == pubkeyOrAlias {
+			rest := make([]*client.PeerInfo, 0, len(peers)-1)
+			rest = append(rest, peers[:i]...)
+			rest = append(rest, peers[i+1:]...)
+			return p, rest, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("peer %q not found", pubkeyOrAlias)
+}
+
+func peerConfFor(p *client.PeerInfo) peerConf {
+	endpoint := p.LANEndpoint
+	if endpoint == "" {
+		endpoint = p.NATEndpoint
+	}
+	if endpoint == "" {
+		endpoint = p.StunEndpoint
+	}
+
+	return peerConf{
+		PublicKey:           p.Pubkey,
+		AllowedIPs:          []string{p.IP + "/32"},
+		Endpoint:            endpoint,
+		PersistentKeepalive: defaultPersistentKeepalive,
+	}
+}
+
+func printShowconf(out showconfOutput) error {
+	switch showconfFormat {
+	case "json":
+		enc := json.NewEncoder(cmdOut())
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+
+	case "yaml":
+		enc := yaml.NewEncoder(cmdOut())
+		defer enc.Close()
+		return enc.Encode(out)
+
+	case "wireguard", "":
+		return printWireguardConf(out)
+
+	default:
+		return fmt.Errorf("invalid output format %q: must be wireguard, json, or yaml", showconfFormat)
+	}
+}
+
+// printWireguardConf renders out via pkg/wgcfg, the same representation
+// printClientConfig and peer import use, so all three stay in sync.
+func printWireguardConf(out showconfOutput) error {
+	wgConfig, err := toWgcfgConfig(out)
+	if err != nil {
+		return err
+	}
+
+	data, err := wgConfig.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to render config: %w", err)
+	}
+
+	// Marshal always opens with an empty "[Interface]" header here (the
+	// config has no interface fields); drop it so only the header below, or
+	// none for --as-peer, is printed.
+	rendered := strings.TrimPrefix(strings.TrimPrefix(string(data), "[Interface]\n"), "\n")
+	if out.Interface != nil {
+		rendered = fmt.Sprintf("[Interface]\nAddress = %s/32\n# PublicKey = %s\nPrivateKey = <INSERT_YOUR_PRIVATE_KEY_HERE>\n\n", out.Interface.Address, out.Interface.PublicKey) + rendered
+	}
+
+	fmt.Print(rendered)
+	return nil
+}
+
+func toWgcfgConfig(out showconfOutput) (wgcfg.Config, error) {
+	var wgConfig wgcfg.Config
+
+	for _, p := range out.Peers {
+		peer, err := toWgcfgPeer(p)
+		if err != nil {
+			return wgcfg.Config{}, err
+		}
+		wgConfig.Peers = append(wgConfig.Peers, peer)
+	}
+
+	return wgConfig, nil
+}
+
+func toWgcfgPeer(p peerConf) (wgcfg.Peer, error) {
+	pubKey, err := wgtypes.ParseKey(p.PublicKey)
+	if err != nil {
+		return wgcfg.Peer{}, fmt.Errorf("invalid public key %q: %w", p.PublicKey, err)
+	}
+
+	allowedIPs := make([]netip.Prefix, 0, len(p.AllowedIPs))
+	for _, ip := range p.AllowedIPs {
+		prefix, err := netip.ParsePrefix(ip)
+		if err != nil {
+			return wgcfg.Peer{}, fmt.Errorf("invalid AllowedIPs %q: %w", ip, err)
+		}
+		allowedIPs = append(allowedIPs, prefix)
+	}
+
+	peer := wgcfg.Peer{
+		PublicKey:           pubKey,
+		AllowedIPs:          allowedIPs,
+		PersistentKeepalive: time.Duration(p.PersistentKeepalive) * time.Second,
+	}
+
+	if p.Endpoint != "" {
+		addr, err := net.ResolveUDPAddr("udp", p.Endpoint)
+		if err != nil {
+			return wgcfg.Peer{}, fmt.Errorf("invalid endpoint %q: %w", p.Endpoint, err)
+		}
+		peer.Endpoint = addr
+	}
+
+	return peer, nil
+}
+
+// cmdOut is the writer showconf prints its JSON and YAML output to; it
+// always returns os.Stdout.
+func cmdOut() *os.File {
+	return os.Stdout
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/pabotesu/valon/valonctl/pkg/client"
+	"github.com/pabotesu/valon/valonctl/pkg/config"
+	"github.com/pabotesu/valon/valond/pkg/relay"
+)
+
+const relayKeepaliveInterval = 15 * time.Second
+
+var (
+	relayPubKey string
+
+	relayCmd = &cobra.Command{
+		Use:   "relay",
+		Short: "Commands for running behind a valond relay peer",
+	}
+
+	relayKeepaliveCmd = &cobra.Command{
+		Use:   "keepalive",
+		Short: "Keep this peer's relay registration alive",
+		Long: `Checks whether this peer's etcd /relay marker points at a relay peer and,
+if so, sends a register beacon to that relay every few seconds so its
+forwarding table doesn't expire this peer's entry (see valond/pkg/relay's
+RegistrationTTL). This is a persistent-keepalive equivalent for peers whose
+direct connections are being relayed.
+
+Intended to run continuously (e.g. under a systemd unit) on the relayed
+peer; while this peer is not relayed it sends nothing and checks the marker
+again on the next tick, so it keeps running either way.`,
+		RunE: runRelayKeepalive,
+	}
+)
+
+func init() {
+	relayKeepaliveCmd.Flags().StringVar(&relayPubKey, "pubkey", "", "this peer's WireGuard public key (required)")
+	relayKeepaliveCmd.MarkFlagRequired("pubkey")
+
+	relayCmd.AddCommand(relayKeepaliveCmd)
+	rootCmd.AddCommand(relayCmd)
+}
+
+func runRelayKeepalive(cmd *cobra.Command, args []string) error {
+	key, err := wgtypes.ParseKey(relayPubKey)
+	if err != nil {
+		return fmt.Errorf("invalid pubkey: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(relayKeepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := sendRelayBeacon(ctx, &cfg.Etcd, &cfg.DDNS, key); err != nil {
+			fmt.Fprintf(os.Stderr, "relay keepalive: %v\n", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// sendRelayBeacon looks up whether this peer is currently relayed and, if
+// so, sends one register beacon to the relay's endpoint.
+func sendRelayBeacon(ctx context.Context, etcdCfg *config.EtcdConfig, ddnsCfg *config.DDNSConfig, key wgtypes.Key) error {
+	etcdClient, err := client.NewEtcdClient(etcdCfg, ddnsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	defer etcdClient.Close()
+
+	peers, err := etcdClient.ListPeers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list peers: %w", err)
+	}
+
+	var relayPubkey string
+	for _, p := range peers {
+		if p.Pubkey == key.String() {
+			relayPubkey = p.RelayMarker
+			break
+		}
+	}
+	if relayPubkey == "" {
+		return nil // not currently relayed; nothing to do
+	}
+
+	var relayEndpoint string
+	for _, p := range peers {
+		if p.Pubkey == relayPubkey {
+			relayEndpoint = p.LANEndpoint
+			if relayEndpoint == "" {
+				relayEndpoint = p.NATEndpoint
+			}
+			break
+		}
+	}
+	if relayEndpoint == "" {
+		return fmt.Errorf("relay %s has no known endpoint", relayPubkey)
+	}
+
+	conn, err := net.Dial("udp", relayEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to reach relay %s: %w", relayEndpoint, err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(relay.EncodeRegisterBeacon(key))
+	return err
+}
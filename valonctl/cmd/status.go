@@ -31,7 +31,7 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Println("===================")
 
 	// Check WireGuard interface
-	wgClient, err := client.NewWireGuardClient()
+	wgClient, err := client.NewWireGuardClient(cfg.WireGuard.Mode)
 	if err != nil {
 		fmt.Printf("WireGuard Interface: ✗ (failed to create client: %v)\n", err)
 	} else {
@@ -58,13 +58,19 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		if err := etcdClient.Ping(ctx); err != nil {
 			fmt.Printf("Etcd: ✗ %v (unreachable: %v)\n", cfg.Etcd.Endpoints, err)
 		} else {
-			// Count registered peers
+			// Count registered peers, split by static vs dynamic
 			peers, err := etcdClient.ListPeers(ctx)
-			peerCount := 0
+			peerCount, staticCount := 0, 0
 			if err == nil {
 				peerCount = len(peers)
+				for _, p := range peers {
+					if p.IsStatic {
+						staticCount++
+					}
+				}
 			}
-			fmt.Printf("Etcd: ✓ Connected (%v, %d peers registered)\n", cfg.Etcd.Endpoints, peerCount)
+			fmt.Printf("Etcd: ✓ Connected (%v, %d peers registered: %d dynamic, %d static)\n",
+				cfg.Etcd.Endpoints, peerCount, peerCount-staticCount, staticCount)
 		}
 	}
 
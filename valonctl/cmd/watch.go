@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/pabotesu/valon/valonctl/pkg/client"
+)
+
+var (
+	watchCmd = &cobra.Command{
+		Use:   "watch",
+		Short: "Stream peer changes from etcd",
+		Long: `Stream PUT/DELETE events under /valon/peers/ to the terminal as they happen.
+Useful for observing endpoint updates and peer registrations live, e.g. while debugging connectivity.
+
+Press Ctrl+C to stop.`,
+		RunE: runWatch,
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	etcdClient, err := client.NewEtcdClient(&cfg.Etcd, &cfg.DDNS)
+	if err != nil {
+		return fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	defer etcdClient.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
+	fmt.Println("Watching /valon/peers/ for changes (Ctrl+C to stop)...")
+
+	watchCh := etcdClient.WatchPeers(ctx)
+	for resp := range watchCh {
+		if err := resp.Err(); err != nil {
+			return fmt.Errorf("watch error: %w", err)
+		}
+		for _, ev := range resp.Events {
+			printWatchEvent(ev)
+		}
+	}
+
+	return nil
+}
+
+func printWatchEvent(ev *clientv3.Event) {
+	key := strings.TrimPrefix(string(ev.Kv.Key), "/valon/")
+
+	switch ev.Type {
+	case clientv3.EventTypePut:
+		fmt.Printf("PUT    %s = %s\n", key, string(ev.Kv.Value))
+	case clientv3.EventTypeDelete:
+		fmt.Printf("DELETE %s\n", key)
+	}
+}
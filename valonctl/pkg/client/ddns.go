@@ -1,10 +1,16 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/pabotesu/valon/valonctl/pkg/identity"
 )
 
 // DDNSClient wraps HTTP client for CoreDNS DDNS API operations
@@ -23,6 +29,85 @@ func NewDDNSClient(baseURL string) *DDNSClient {
 	}
 }
 
+// EndpointUpdate describes an /api/endpoint registration. Timestamp and
+// Nonce are filled in by RegisterEndpointSigned; leave them zero when using
+// the IP-authorized path and posting through RegisterEndpoint.
+type EndpointUpdate struct {
+	PubKey      string `json:"pubkey"`
+	LANEndpoint string `json:"lan_endpoint"`
+	Alias       string `json:"alias,omitempty"`
+	Timestamp   int64  `json:"timestamp,omitempty"`
+	Nonce       string `json:"nonce,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+}
+
+// RegisterEndpoint posts an unsigned endpoint update, relying on the
+// server's IP-based authorization (DDNSAuthMode "ip" or "both").
+func (d *DDNSClient) RegisterEndpoint(ctx context.Context, update EndpointUpdate) error {
+	return d.postEndpoint(ctx, update)
+}
+
+// RegisterEndpointSigned signs update with key's identity private key and
+// posts it, for use against a Discovery Role running DDNSAuthMode
+// "signature" or "both" - this is what lets a peer register its endpoint
+// after roaming to a network where its WireGuard IP no longer matches what
+// etcd has on file.
+func (d *DDNSClient) RegisterEndpointSigned(ctx context.Context, update EndpointUpdate, key *identity.KeyPair) error {
+	nonce, err := randomNonce()
+	if err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	update.Timestamp = time.Now().Unix()
+	update.Nonce = nonce
+	update.Signature = key.Sign(canonicalEndpointMessage(update))
+
+	return d.postEndpoint(ctx, update)
+}
+
+func (d *DDNSClient) postEndpoint(ctx context.Context, update EndpointUpdate) error {
+	body, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal endpoint update: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.baseURL+"/api/endpoint", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send endpoint update: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DDNS API rejected endpoint update: status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// canonicalEndpointMessage must match coredns-plugin/valon's
+// canonicalEndpointMessage byte-for-byte, since the server reconstructs the
+// same message from the fields it receives and compares the signature
+// against it.
+func canonicalEndpointMessage(update EndpointUpdate) []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%s\n%d\n%s",
+		update.PubKey, update.LANEndpoint, update.Alias, update.Timestamp, update.Nonce))
+}
+
+// randomNonce returns a short random hex string, unique enough to pair with
+// the timestamp window for replay protection.
+func randomNonce() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
 // Ping checks if the DDNS API is reachable
 func (d *DDNSClient) Ping(ctx context.Context) error {
 	// Try to reach the base URL
@@ -3,8 +3,10 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -17,6 +19,7 @@ import (
 	clientv3 "go.etcd.io/etcd/client/v3"
 
 	"github.com/pabotesu/valon/valonctl/pkg/config"
+	"github.com/pabotesu/valon/valonctl/pkg/encoding"
 )
 
 const (
@@ -35,13 +38,29 @@ type EtcdClient struct {
 
 // PeerInfo represents a peer's information stored in etcd
 type PeerInfo struct {
-	Pubkey      string // WireGuard public key (base64)
-	IP          string // WireGuard IP address
-	Alias       string // User-friendly alias name
-	Endpoint    string // Last known endpoint (IP:port or 0.0.0.0:0 for offline) - deprecated, use LANEndpoint/NATEndpoint
-	LANEndpoint string // LAN endpoint from DDNS registration
-	NATEndpoint string // NAT endpoint from WireGuard observation
-	LastSeen    time.Time
+	Pubkey        string // WireGuard public key (base64)
+	IP            string // WireGuard IP address
+	IPv6          string // WireGuard IPv6 address, from /wg_ip6; empty for a v4-only peer. Populated by ListPeers/GetPeer; AddPeer has no flag to write it yet, so it must currently be set some other way (e.g. directly in etcd).
+	Alias         string // User-friendly alias name
+	Endpoint      string // Pinned endpoint (IP:port) for a static peer; also the legacy "last known endpoint" field for dynamic peers predating LANEndpoint/NATEndpoint
+	LANEndpoint   string // LAN endpoint from DDNS registration
+	LANEndpointV6 string // LAN endpoint's IPv6 counterpart, from /endpoints/lan6
+	NATEndpoint   string // NAT endpoint from WireGuard observation
+	NATEndpointV6 string // NAT endpoint's IPv6 counterpart, from /endpoints/nated6
+	StunEndpoint  string // STUN-reflexive endpoint self-reported via netcheck
+	NATType       string // NAT classification reported by netcheck (open, eim, edm, symmetric)
+	IdentityPub   string // Ed25519 identity public key (base64), used for signed DDNS auth
+	Role          string // declared role (e.g. "relay"), empty for a regular peer
+	RelayMarker   string // pubkey of the relay peer currently standing in for this peer's endpoint, if any
+	IsStatic      bool   // WireGuard-only peer with a pinned Endpoint; does not participate in DDNS/NAT-endpoint discovery
+	LastSeen      time.Time
+
+	// TsigSecret, if set, is provisioned at /valon/tsig/<label> (where
+	// <label> is the peer's base32 DNS label) so the peer can register its
+	// LAN endpoint via an authenticated RFC 2136 Dynamic UPDATE instead of
+	// the plaintext DDNS HTTP API - see GenerateTsigSecret and
+	// coredns-plugin/valon's handleUpdate.
+	TsigSecret string
 }
 
 // NewEtcdClient creates a new etcd client from configuration
@@ -106,17 +125,38 @@ func (e *EtcdClient) AddPeer(ctx context.Context, peer *PeerInfo) error {
 	peerPrefix := path.Join(EtcdKeyPrefix, "peers", peer.Pubkey)
 	aliasKey := path.Join(EtcdKeyPrefix, "aliases", peer.Alias)
 
-	// Use transaction to ensure atomic dual write
-	txn := e.client.Txn(ctx).If(
-		// Check alias doesn't already exist
-		clientv3.Compare(clientv3.Version(aliasKey), "=", 0),
-	).Then(
-		// Write peer info (wg_ip is the primary field)
+	// Write peer info (wg_ip is the primary field)
+	ops := []clientv3.Op{
 		clientv3.OpPut(path.Join(peerPrefix, "wg_ip"), peer.IP),
 		clientv3.OpPut(path.Join(peerPrefix, "alias"), peer.Alias),
 		// Write alias reference
 		clientv3.OpPut(aliasKey, peer.Pubkey),
-	)
+	}
+	if peer.IdentityPub != "" {
+		ops = append(ops, clientv3.OpPut(path.Join(peerPrefix, "identity_pub"), peer.IdentityPub))
+	}
+	if peer.Role != "" {
+		ops = append(ops, clientv3.OpPut(path.Join(peerPrefix, "role"), peer.Role))
+	}
+	if peer.Endpoint != "" {
+		ops = append(ops, clientv3.OpPut(path.Join(peerPrefix, "endpoint"), peer.Endpoint))
+	}
+	if peer.IsStatic {
+		ops = append(ops, clientv3.OpPut(path.Join(peerPrefix, "static"), "true"))
+	}
+	if peer.TsigSecret != "" {
+		label, err := encoding.PubkeyToLabel(peer.Pubkey)
+		if err != nil {
+			return fmt.Errorf("failed to derive DNS label for TSIG secret: %w", err)
+		}
+		ops = append(ops, clientv3.OpPut(path.Join(EtcdKeyPrefix, "tsig", label), peer.TsigSecret))
+	}
+
+	// Use transaction to ensure atomic dual write
+	txn := e.client.Txn(ctx).If(
+		// Check alias doesn't already exist
+		clientv3.Compare(clientv3.Version(aliasKey), "=", 0),
+	).Then(ops...)
 
 	resp, err := txn.Commit()
 	if err != nil {
@@ -130,6 +170,18 @@ func (e *EtcdClient) AddPeer(ctx context.Context, peer *PeerInfo) error {
 	return nil
 }
 
+// GenerateTsigSecret returns a random 256-bit TSIG shared secret, base64
+// encoded as miekg/dns's TsigVerify/TsigGenerate expect. Pass the result as
+// PeerInfo.TsigSecret to AddPeer, then deliver it to the peer out-of-band
+// (valonctl does not display it again after peer add).
+func GenerateTsigSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate TSIG secret: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
 // RemovePeer removes a peer from etcd by pubkey or alias
 func (e *EtcdClient) RemovePeer(ctx context.Context, pubkeyOrAlias string) error {
 	// Try to detect if it's a pubkey (base64) or alias
@@ -237,30 +289,10 @@ func (e *EtcdClient) ListPeers(ctx context.Context) ([]*PeerInfo, error) {
 	peerMap := make(map[string]*PeerInfo)
 
 	for _, kv := range resp.Kvs {
-		keyStr := string(kv.Key)
-		// Remove prefix to get: <pubkey>/field or <pubkey>/endpoints/type
-		relKey := strings.TrimPrefix(keyStr, prefix)
-
-		// Find pubkey by looking for known field patterns
-		// Known fields: wg_ip, ip, alias, endpoint, endpoints/, last_seen
-		var pubkey, fieldPath string
-
-		if idx := strings.Index(relKey, "/wg_ip"); idx != -1 {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else if idx := strings.Index(relKey, "/ip"); idx != -1 && !strings.Contains(relKey[idx:], "/wg_ip") {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else if idx := strings.Index(relKey, "/alias"); idx != -1 {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else if idx := strings.Index(relKey, "/endpoint"); idx != -1 {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else if idx := strings.Index(relKey, "/last_seen"); idx != -1 {
-			pubkey = relKey[:idx]
-			fieldPath = relKey[idx+1:]
-		} else {
+		relKey := strings.TrimPrefix(string(kv.Key), prefix)
+
+		pubkey, fieldPath, ok := SplitPeerKey(relKey)
+		if !ok {
 			continue
 		}
 
@@ -270,37 +302,7 @@ func (e *EtcdClient) ListPeers(ctx context.Context) ([]*PeerInfo, error) {
 			}
 		}
 
-		// Parse field path
-		parts := strings.Split(fieldPath, "/")
-		if len(parts) == 0 {
-			continue
-		}
-
-		switch parts[0] {
-		case "wg_ip", "ip": // Support both wg_ip and ip (legacy)
-			peerMap[pubkey].IP = string(kv.Value)
-		case "alias":
-			peerMap[pubkey].Alias = string(kv.Value)
-		case "endpoint":
-			peerMap[pubkey].Endpoint = string(kv.Value)
-		case "endpoints":
-			if len(parts) >= 2 {
-				endpointType := parts[1]
-				if endpointType == "lan" {
-					peerMap[pubkey].LANEndpoint = string(kv.Value)
-				} else if endpointType == "nated" {
-					peerMap[pubkey].NATEndpoint = string(kv.Value)
-				}
-			}
-			// Also store in legacy Endpoint field for backward compatibility
-			if peerMap[pubkey].Endpoint == "" {
-				peerMap[pubkey].Endpoint = string(kv.Value)
-			}
-		case "last_seen":
-			if t, err := time.Parse(time.RFC3339, string(kv.Value)); err == nil {
-				peerMap[pubkey].LastSeen = t
-			}
-		}
+		applyPeerField(peerMap[pubkey], fieldPath, string(kv.Value))
 	}
 
 	// Convert map to slice
@@ -312,6 +314,178 @@ func (e *EtcdClient) ListPeers(ctx context.Context) ([]*PeerInfo, error) {
 	return peers, nil
 }
 
+// GetPeer fetches a single peer's current record. Used by the netmap
+// coordinator (see pkg/netmap) to reconstruct a full peer record after a
+// single-field watch event, mirroring coredns-plugin/valon's
+// EtcdStore.Get.
+func (e *EtcdClient) GetPeer(ctx context.Context, pubkey string) (*PeerInfo, error) {
+	prefix := path.Join(EtcdKeyPrefix, "peers", pubkey) + "/"
+	resp, err := e.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get peer: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+
+	peer := &PeerInfo{Pubkey: pubkey}
+	for _, kv := range resp.Kvs {
+		applyPeerField(peer, strings.TrimPrefix(string(kv.Key), prefix), string(kv.Value))
+	}
+	return peer, nil
+}
+
+// SplitPeerKey splits a key relative to "<EtcdKeyPrefix>/peers/" (i.e.
+// "<pubkey>/<field>" or "<pubkey>/endpoints/<type>") into the pubkey and
+// field path, using the same known-field-prefix matching ListPeers has
+// always used, expressed as a standalone helper so GetPeer and the netmap
+// coordinator can find the pubkey a single watch event belongs to without
+// duplicating the matching order. The IPv6 fields (wg_ip6,
+// endpoints/lan6, endpoints/nated6) need no markers of their own: the
+// "/wg_ip" and "/endpoint" markers already match them as a prefix.
+func SplitPeerKey(relKey string) (pubkey, fieldPath string, ok bool) {
+	if idx := strings.Index(relKey, "/wg_ip"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/ip"); idx != -1 && !strings.Contains(relKey[idx:], "/wg_ip") {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/alias"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/endpoint"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/last_seen"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/nat_type"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/identity_pub"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/role"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/relay"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	if idx := strings.Index(relKey, "/static"); idx != -1 {
+		return relKey[:idx], relKey[idx+1:], true
+	}
+	return "", "", false
+}
+
+// applyPeerField sets the PeerInfo field named by its etcd key field path
+// (see SplitPeerKey) to value.
+func applyPeerField(p *PeerInfo, fieldPath, value string) {
+	parts := strings.Split(fieldPath, "/")
+	if len(parts) == 0 {
+		return
+	}
+
+	switch parts[0] {
+	case "wg_ip", "ip": // Support both wg_ip and ip (legacy)
+		p.IP = value
+	case "wg_ip6":
+		p.IPv6 = value
+	case "alias":
+		p.Alias = value
+	case "endpoint":
+		p.Endpoint = value
+	case "endpoints":
+		if len(parts) >= 2 {
+			switch parts[1] {
+			case "lan":
+				p.LANEndpoint = value
+			case "lan6":
+				p.LANEndpointV6 = value
+			case "nated":
+				p.NATEndpoint = value
+			case "nated6":
+				p.NATEndpointV6 = value
+			case "stun":
+				p.StunEndpoint = value
+			}
+		}
+		// Also store in legacy Endpoint field for backward compatibility
+		if p.Endpoint == "" {
+			p.Endpoint = value
+		}
+	case "nat_type":
+		p.NATType = value
+	case "identity_pub":
+		p.IdentityPub = value
+	case "role":
+		p.Role = value
+	case "relay":
+		p.RelayMarker = value
+	case "static":
+		p.IsStatic = value == "true"
+	case "last_seen":
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			p.LastSeen = t
+		}
+	}
+}
+
+// WatchPeers returns a channel of raw etcd watch responses for all keys
+// under /valon/peers/, for callers (e.g. `valonctl watch`) that want to
+// stream peer changes as they happen rather than polling ListPeers.
+func (e *EtcdClient) WatchPeers(ctx context.Context) clientv3.WatchChan {
+	prefix := path.Join(EtcdKeyPrefix, "peers") + "/"
+	return e.client.Watch(ctx, prefix, clientv3.WithPrefix())
+}
+
+// WatchPeersFromRevision is like WatchPeers but resumes from a specific
+// revision (inclusive), for a caller that already has a consistent
+// baseline - e.g. pkg/netmap's Coordinator, which establishes one via
+// CurrentRevision before it starts watching so it can't miss an event
+// racing its initial ListPeers.
+func (e *EtcdClient) WatchPeersFromRevision(ctx context.Context, rev int64) clientv3.WatchChan {
+	prefix := path.Join(EtcdKeyPrefix, "peers") + "/"
+	return e.client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithRev(rev))
+}
+
+// CurrentRevision returns etcd's current revision. Read it before a
+// ListPeers call (or, as pkg/netmap's Coordinator does, pass
+// revision+1 to WatchPeersFromRevision) to establish a consistent
+// list-then-watch baseline.
+func (e *EtcdClient) CurrentRevision(ctx context.Context) (int64, error) {
+	resp, err := e.client.Get(ctx, EtcdKeyPrefix, clientv3.WithCountOnly())
+	if err != nil {
+		return 0, fmt.Errorf("failed to read etcd revision: %w", err)
+	}
+	return resp.Header.Revision, nil
+}
+
+// RegisterShortID writes the etcd secondary index (/valon/shortids/<id>)
+// that lets a SchemeShortID DNS label (see pkg/encoding) be resolved back
+// to the pubkey it was generated from.
+func (e *EtcdClient) RegisterShortID(ctx context.Context, shortID, pubkey string) error {
+	key := path.Join(EtcdKeyPrefix, "shortids", shortID)
+	if _, err := e.client.Put(ctx, key, pubkey); err != nil {
+		return fmt.Errorf("failed to register short ID: %w", err)
+	}
+	return nil
+}
+
+// ResolveShortID looks up the pubkey a short ID was registered for via
+// RegisterShortID. It is the backing implementation for an
+// encoding.ShortIDResolver.
+func (e *EtcdClient) ResolveShortID(ctx context.Context, shortID string) (string, error) {
+	key := path.Join(EtcdKeyPrefix, "shortids", shortID)
+	resp, err := e.client.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve short ID: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", fmt.Errorf("short ID %q not found", shortID)
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
 // loadTLSConfig creates a TLS configuration from certificate paths
 func loadTLSConfig(cfg *config.TLSConfig) (*tls.Config, error) {
 	tlsConfig := &tls.Config{}
@@ -8,29 +8,89 @@ import (
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
-// WireGuardClient wraps wgctrl for WireGuard operations
-type WireGuardClient struct {
+// Backend abstracts the operations WireGuardClient needs from a concrete
+// WireGuard implementation, so it can run against the kernel module
+// (KernelBackend, via wgctrl) or a userspace device (UserspaceBackend, via
+// wireguard-go + gVisor netstack) without the rest of valonctl caring which.
+type Backend interface {
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+	Close() error
+}
+
+// KernelBackend drives the in-kernel WireGuard implementation via wgctrl.
+// This is the original, and still default, backend.
+type KernelBackend struct {
 	client *wgctrl.Client
 }
 
-// NewWireGuardClient creates a new WireGuard client
-func NewWireGuardClient() (*WireGuardClient, error) {
+// NewKernelBackend opens a wgctrl client talking to the kernel WireGuard
+// implementation. It fails if no kernel WireGuard support is available
+// (missing module, no permissions, etc.).
+func NewKernelBackend() (*KernelBackend, error) {
 	client, err := wgctrl.New()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create wgctrl client: %w", err)
 	}
+	return &KernelBackend{client: client}, nil
+}
 
-	return &WireGuardClient{client: client}, nil
+func (k *KernelBackend) Device(name string) (*wgtypes.Device, error) {
+	return k.client.Device(name)
+}
+
+func (k *KernelBackend) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	return k.client.ConfigureDevice(name, cfg)
+}
+
+func (k *KernelBackend) Close() error {
+	return k.client.Close()
+}
+
+// WireGuardClient wraps a Backend (kernel or userspace) for VALON's
+// WireGuard operations.
+type WireGuardClient struct {
+	backend Backend
+}
+
+// NewWireGuardClient creates a new WireGuard client using the requested
+// backend mode:
+//   - "kernel": KernelBackend only, error if unavailable.
+//   - "userspace": UserspaceBackend only (wireguard-go + gVisor netstack).
+//   - "auto" or "": probe KernelBackend first, fall back to UserspaceBackend.
+//
+// This corresponds to the wireguard.mode config field.
+func NewWireGuardClient(mode string) (*WireGuardClient, error) {
+	switch mode {
+	case "kernel":
+		backend, err := NewKernelBackend()
+		if err != nil {
+			return nil, err
+		}
+		return &WireGuardClient{backend: backend}, nil
+
+	case "userspace":
+		return &WireGuardClient{backend: NewUserspaceBackend()}, nil
+
+	case "auto", "":
+		if backend, err := NewKernelBackend(); err == nil {
+			return &WireGuardClient{backend: backend}, nil
+		}
+		return &WireGuardClient{backend: NewUserspaceBackend()}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid wireguard.mode %q: must be auto, kernel, or userspace", mode)
+	}
 }
 
-// Close closes the WireGuard client
+// Close closes the WireGuard client's backend.
 func (w *WireGuardClient) Close() error {
-	return w.client.Close()
+	return w.backend.Close()
 }
 
 // GetPublicKey retrieves the public key for the specified interface
 func (w *WireGuardClient) GetPublicKey(interfaceName string) (string, error) {
-	device, err := w.client.Device(interfaceName)
+	device, err := w.backend.Device(interfaceName)
 	if err != nil {
 		return "", fmt.Errorf("failed to get device %s: %w", interfaceName, err)
 	}
@@ -39,7 +99,7 @@ func (w *WireGuardClient) GetPublicKey(interfaceName string) (string, error) {
 
 // GetDevice retrieves device information for the specified interface
 func (w *WireGuardClient) GetDevice(interfaceName string) (*wgtypes.Device, error) {
-	device, err := w.client.Device(interfaceName)
+	device, err := w.backend.Device(interfaceName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get device %s: %w", interfaceName, err)
 	}
@@ -71,13 +131,147 @@ func (w *WireGuardClient) AddPeer(interfaceName string, pubkey string, allowedIP
 		Peers: []wgtypes.PeerConfig{peerConfig},
 	}
 
-	if err := w.client.ConfigureDevice(interfaceName, cfg); err != nil {
+	if err := w.backend.ConfigureDevice(interfaceName, cfg); err != nil {
 		return fmt.Errorf("failed to add peer: %w", err)
 	}
 
 	return nil
 }
 
+// SetPeerEndpoint pins a peer's Endpoint without touching its AllowedIPs.
+// This is used for static (WireGuard-only) peers whose endpoint is fixed by
+// the operator rather than learned dynamically via DDNS.
+func (w *WireGuardClient) SetPeerEndpoint(interfaceName, pubkey, endpoint string) error {
+	key, err := wgtypes.ParseKey(pubkey)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint: %w", err)
+	}
+
+	cfg := wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:  key,
+			Endpoint:   udpAddr,
+			UpdateOnly: true,
+		}},
+	}
+
+	if err := w.backend.ConfigureDevice(interfaceName, cfg); err != nil {
+		return fmt.Errorf("failed to set peer endpoint: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateOpts controls how UpdatePeers reconciles the WireGuard device
+// against a desired peer set.
+type UpdateOpts struct {
+	// Remove lists pubkeys to remove from the device, applied in the same
+	// ConfigureDevice call as the adds/updates from desired.
+	Remove []string
+}
+
+// UpdatePeers reconciles the WireGuard device against desired in a single
+// ConfigureDevice call: peers not yet on the device are added, peers
+// already present have their AllowedIPs/Endpoint pushed via UpdateOnly so
+// unrelated peer state (handshake stats, etc.) is left untouched, and
+// pubkeys listed in opts.Remove are removed. This replaces a naive
+// add-or-remove-per-peer loop with one netlink transaction, which matters
+// once a caller (e.g. `peer import`) is reconciling dozens of peers at once.
+func (w *WireGuardClient) UpdatePeers(interfaceName string, desired []*PeerInfo, opts UpdateOpts) error {
+	device, err := w.backend.Device(interfaceName)
+	if err != nil {
+		return fmt.Errorf("failed to get device %s: %w", interfaceName, err)
+	}
+
+	existing := make(map[string]bool, len(device.Peers))
+	for _, p := range device.Peers {
+		existing[p.PublicKey.String()] = true
+	}
+
+	peerConfigs := make([]wgtypes.PeerConfig, 0, len(desired)+len(opts.Remove))
+	for _, peer := range desired {
+		key, err := wgtypes.ParseKey(peer.Pubkey)
+		if err != nil {
+			return fmt.Errorf("invalid public key %q: %w", peer.Pubkey, err)
+		}
+
+		peerConfig := wgtypes.PeerConfig{PublicKey: key}
+
+		if peer.IP != "" {
+			_, ipNet, err := net.ParseCIDR(peer.IP + "/32")
+			if err != nil {
+				return fmt.Errorf("invalid IP %q for peer %s: %w", peer.IP, peer.Pubkey, err)
+			}
+			peerConfig.AllowedIPs = []net.IPNet{*ipNet}
+			peerConfig.ReplaceAllowedIPs = true
+		}
+
+		if peer.Endpoint != "" {
+			udpAddr, err := net.ResolveUDPAddr("udp", peer.Endpoint)
+			if err != nil {
+				return fmt.Errorf("invalid endpoint %q for peer %s: %w", peer.Endpoint, peer.Pubkey, err)
+			}
+			peerConfig.Endpoint = udpAddr
+		}
+
+		if existing[peer.Pubkey] {
+			peerConfig.UpdateOnly = true
+		}
+
+		peerConfigs = append(peerConfigs, peerConfig)
+	}
+
+	for _, pubkey := range opts.Remove {
+		key, err := wgtypes.ParseKey(pubkey)
+		if err != nil {
+			return fmt.Errorf("invalid public key %q: %w", pubkey, err)
+		}
+		peerConfigs = append(peerConfigs, wgtypes.PeerConfig{PublicKey: key, Remove: true})
+	}
+
+	if len(peerConfigs) == 0 {
+		return nil
+	}
+
+	cfg := wgtypes.Config{Peers: peerConfigs}
+	if err := w.backend.ConfigureDevice(interfaceName, cfg); err != nil {
+		return fmt.Errorf("failed to update peers: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceAllPeers reconciles the device so its peer set exactly matches
+// desired in a single ConfigureDevice call: peers in desired are added or
+// updated, and any peer currently on the device but absent from desired is
+// removed.
+func (w *WireGuardClient) ReplaceAllPeers(interfaceName string, desired []*PeerInfo) error {
+	device, err := w.backend.Device(interfaceName)
+	if err != nil {
+		return fmt.Errorf("failed to get device %s: %w", interfaceName, err)
+	}
+
+	wanted := make(map[string]bool, len(desired))
+	for _, peer := range desired {
+		wanted[peer.Pubkey] = true
+	}
+
+	var opts UpdateOpts
+	for _, p := range device.Peers {
+		pubkey := p.PublicKey.String()
+		if !wanted[pubkey] {
+			opts.Remove = append(opts.Remove, pubkey)
+		}
+	}
+
+	return w.UpdatePeers(interfaceName, desired, opts)
+}
+
 // RemovePeer removes a peer from the WireGuard interface
 func (w *WireGuardClient) RemovePeer(interfaceName string, pubkey string) error {
 	// Parse public key
@@ -96,7 +290,7 @@ func (w *WireGuardClient) RemovePeer(interfaceName string, pubkey string) error
 		Peers: []wgtypes.PeerConfig{peerConfig},
 	}
 
-	if err := w.client.ConfigureDevice(interfaceName, cfg); err != nil {
+	if err := w.backend.ConfigureDevice(interfaceName, cfg); err != nil {
 		return fmt.Errorf("failed to remove peer: %w", err)
 	}
 
@@ -105,7 +299,7 @@ func (w *WireGuardClient) RemovePeer(interfaceName string, pubkey string) error
 
 // IsInterfaceUp checks if the WireGuard interface exists and is configured
 func (w *WireGuardClient) IsInterfaceUp(interfaceName string) (bool, error) {
-	_, err := w.client.Device(interfaceName)
+	_, err := w.backend.Device(interfaceName)
 	if err != nil {
 		return false, nil // Interface doesn't exist or not accessible
 	}
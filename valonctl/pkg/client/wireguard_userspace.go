@@ -0,0 +1,274 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// UserspaceBackend runs WireGuard entirely in userspace using wireguard-go
+// bound to a gVisor netstack TUN, for hosts without kernel WireGuard
+// support: macOS, containers, some BSDs, and CI. It implements Backend so
+// the rest of valonctl (DDNS registration, etcd peer sync, `valonctl peer
+// add/remove`) doesn't need to know which backend is active.
+type UserspaceBackend struct {
+	mu      sync.Mutex
+	devices map[string]*userspaceDevice
+}
+
+// userspaceDevice is one interface's worth of userspace WireGuard state: the
+// wireguard-go device, the netstack it is bound to (for the loopback
+// forwarder), and the peer set needed to answer Device() queries.
+type userspaceDevice struct {
+	dev    *device.Device
+	tnet   *netstack.Net
+	pubkey wgtypes.Key
+	peers  map[wgtypes.Key]wgtypes.PeerConfig
+}
+
+// NewUserspaceBackend creates an empty UserspaceBackend. Devices are created
+// lazily on first ConfigureDevice call, mirroring how wgctrl only requires
+// the kernel interface to exist by the time it's configured.
+func NewUserspaceBackend() *UserspaceBackend {
+	return &UserspaceBackend{devices: make(map[string]*userspaceDevice)}
+}
+
+func (u *UserspaceBackend) Device(name string) (*wgtypes.Device, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ud, ok := u.devices[name]
+	if !ok {
+		return nil, fmt.Errorf("userspace device %q not configured", name)
+	}
+
+	peers := make([]wgtypes.Peer, 0, len(ud.peers))
+	for _, p := range ud.peers {
+		peers = append(peers, wgtypes.Peer{
+			PublicKey:  p.PublicKey,
+			Endpoint:   p.Endpoint,
+			AllowedIPs: p.AllowedIPs,
+		})
+	}
+
+	return &wgtypes.Device{
+		Name:      name,
+		Type:      wgtypes.LinuxKernel, // wgtypes has no "userspace" device type; closest semantic match
+		PublicKey: ud.pubkey,
+		Peers:     peers,
+	}, nil
+}
+
+func (u *UserspaceBackend) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	ud, ok := u.devices[name]
+	if !ok {
+		var err error
+		ud, err = newUserspaceDevice(name)
+		if err != nil {
+			return fmt.Errorf("failed to create userspace device %q: %w", name, err)
+		}
+		u.devices[name] = ud
+	}
+
+	if cfg.PrivateKey != nil {
+		ud.pubkey = cfg.PrivateKey.PublicKey()
+	}
+
+	for _, p := range cfg.Peers {
+		if p.Remove {
+			delete(ud.peers, p.PublicKey)
+			continue
+		}
+		if existing, ok := ud.peers[p.PublicKey]; ok && !p.ReplaceAllowedIPs {
+			p.AllowedIPs = append(existing.AllowedIPs, p.AllowedIPs...)
+		}
+		ud.peers[p.PublicKey] = p
+	}
+
+	return ud.apply(cfg)
+}
+
+func (u *UserspaceBackend) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for name, ud := range u.devices {
+		ud.dev.Close()
+		delete(u.devices, name)
+	}
+	return nil
+}
+
+// newUserspaceDevice builds a gVisor netstack TUN and binds a wireguard-go
+// device to it. The netstack is created with no local addresses, and
+// configuring peers (apply) does not add any.
+func newUserspaceDevice(name string) (*userspaceDevice, error) {
+	tun, tnet, err := netstack.CreateNetTUN(nil, nil, device.DefaultMTU)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create netstack TUN: %w", err)
+	}
+
+	logger := device.NewLogger(device.LogLevelError, fmt.Sprintf("valonctl(%s) ", name))
+	dev := device.NewDevice(tun, conn.NewDefaultBind(), logger)
+
+	return &userspaceDevice{
+		dev:   dev,
+		tnet:  tnet,
+		peers: make(map[wgtypes.Key]wgtypes.PeerConfig),
+	}, nil
+}
+
+// apply pushes cfg to the wireguard-go device via its UAPI config protocol,
+// the same interface `wg setconf` uses against the kernel module.
+func (ud *userspaceDevice) apply(cfg wgtypes.Config) error {
+	var b strings.Builder
+
+	if cfg.PrivateKey != nil {
+		fmt.Fprintf(&b, "private_key=%x\n", cfg.PrivateKey[:])
+	}
+	if cfg.ListenPort != nil {
+		fmt.Fprintf(&b, "listen_port=%d\n", *cfg.ListenPort)
+	}
+
+	for _, p := range cfg.Peers {
+		fmt.Fprintf(&b, "public_key=%x\n", p.PublicKey[:])
+		if p.Remove {
+			b.WriteString("remove=true\n")
+			continue
+		}
+		if p.ReplaceAllowedIPs {
+			b.WriteString("replace_allowed_ips=true\n")
+		}
+		if p.Endpoint != nil {
+			fmt.Fprintf(&b, "endpoint=%s\n", p.Endpoint.String())
+		}
+		for _, allowed := range p.AllowedIPs {
+			fmt.Fprintf(&b, "allowed_ip=%s\n", allowed.String())
+		}
+	}
+
+	if err := ud.dev.IpcSet(b.String()); err != nil {
+		return fmt.Errorf("failed to apply UAPI config: %w", err)
+	}
+	if err := ud.dev.Up(); err != nil {
+		return fmt.Errorf("failed to bring up userspace device: %w", err)
+	}
+	return nil
+}
+
+// PortForward describes one loopback->peer port mapping served by
+// ForwardLoopback.
+type PortForward struct {
+	LocalPort int    // port to listen on at 127.0.0.1
+	PeerAddr  string // peer IP:port inside the netstack to forward to
+	Proto     string // "tcp" or "udp"
+}
+
+// ForwardLoopback serves local applications that want to reach a peer only
+// reachable via the userspace netstack (it isn't a real OS network
+// interface, so peer IPs aren't directly routable from the host). For each
+// mapping it listens on 127.0.0.1:LocalPort using the real OS network stack,
+// and for every accepted connection dials PeerAddr through tnet - the
+// netstack bound to the userspace WireGuard device - splicing the two
+// together.
+func ForwardLoopback(tnet *netstack.Net, mappings []PortForward) error {
+	for _, m := range mappings {
+		switch m.Proto {
+		case "tcp", "":
+			if err := forwardTCP(tnet, m); err != nil {
+				return err
+			}
+		case "udp":
+			if err := forwardUDP(tnet, m); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid forward proto %q for port %d", m.Proto, m.LocalPort)
+		}
+	}
+	return nil
+}
+
+func forwardTCP(tnet *netstack.Net, m PortForward) error {
+	ln, err := net.Listen("tcp", resolveLoopback(m.LocalPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen on loopback port %d: %w", m.LocalPort, err)
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go proxyTCP(conn, tnet, m.PeerAddr)
+		}
+	}()
+
+	return nil
+}
+
+func proxyTCP(local net.Conn, tnet *netstack.Net, peerAddr string) {
+	defer local.Close()
+
+	remote, err := tnet.Dial("tcp", peerAddr)
+	if err != nil {
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(remote, local); done <- struct{}{} }()
+	go func() { io.Copy(local, remote); done <- struct{}{} }()
+	<-done
+}
+
+func forwardUDP(tnet *netstack.Net, m PortForward) error {
+	// UDP forwarding is session-less: a single local listener fans out to
+	// one long-lived remote association per peer address, since netcheck
+	// and DNS are the only userspace-mode UDP consumers today and both are
+	// single-peer.
+	local, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(netip.MustParseAddrPort(resolveLoopback(m.LocalPort))))
+	if err != nil {
+		return fmt.Errorf("failed to listen on loopback UDP port %d: %w", m.LocalPort, err)
+	}
+
+	remote, err := tnet.Dial("udp", m.PeerAddr)
+	if err != nil {
+		local.Close()
+		return fmt.Errorf("failed to dial peer %s over netstack: %w", m.PeerAddr, err)
+	}
+
+	go func() {
+		defer local.Close()
+		defer remote.Close()
+		buf := make([]byte, 65535)
+		for {
+			n, _, err := local.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			if _, err := remote.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func resolveLoopback(port int) string {
+	return "127.0.0.1:" + strconv.Itoa(port)
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/pabotesu/valon/valonctl/pkg/validation"
 )
 
 const (
@@ -14,9 +16,10 @@ const (
 
 // Config represents the valonctl configuration file structure
 type Config struct {
-	WireGuard WireGuardConfig `yaml:"wireguard"`
-	Etcd      EtcdConfig      `yaml:"etcd"`
-	DDNS      DDNSConfig      `yaml:"ddns"`
+	WireGuard WireGuardConfig   `yaml:"wireguard"`
+	Etcd      EtcdConfig        `yaml:"etcd"`
+	DDNS      DDNSConfig        `yaml:"ddns"`
+	Aliases   AliasPolicyConfig `yaml:"aliases,omitempty"`
 }
 
 // WireGuardConfig holds WireGuard interface configuration
@@ -25,6 +28,25 @@ type WireGuardConfig struct {
 	IP        string `yaml:"ip"`        // Discovery Role's WireGuard IP (e.g., "100.100.0.1")
 	Endpoint  string `yaml:"endpoint"`  // Discovery Role's public endpoint (e.g., "192.168.1.100:51820")
 	Network   string `yaml:"network"`   // WireGuard network CIDR (e.g., "100.100.0.0/24") for IP auto-allocation
+
+	// Mode selects the WireGuard backend: "auto" (default, probe kernel then
+	// fall back to userspace), "kernel" (wgctrl, error if unavailable), or
+	// "userspace" (wireguard-go + gVisor netstack, for hosts without root or
+	// kernel WireGuard support).
+	Mode string `yaml:"mode,omitempty"`
+
+	// Role declares this node's function in the overlay. The empty string
+	// is a regular peer; "relay" means this node runs a valond relay
+	// server (valond/pkg/relay) that other peers can be rerouted through
+	// when their direct connection attempts get stuck (see the CoreDNS
+	// plugin's relay_stuck_threshold).
+	Role string `yaml:"role,omitempty"`
+
+	// LazyPeers mirrors the CoreDNS plugin's `lazy_peers` Corefile option, so
+	// valonctl can report/configure the same policy it expects the running
+	// Discovery Role to use.
+	LazyPeers         bool   `yaml:"lazy_peers,omitempty"`             // enable lazy peer materialization
+	LazyIdleThreshold string `yaml:"wg_lazy_idle_threshold,omitempty"` // e.g., "5m"
 }
 
 // EtcdConfig holds etcd connection settings
@@ -43,6 +65,37 @@ type TLSConfig struct {
 // DDNSConfig holds CoreDNS DDNS API settings
 type DDNSConfig struct {
 	APIURL string `yaml:"api_url"` // e.g., "http://localhost:8053"
+
+	// AuthMode mirrors the CoreDNS plugin's `auth_mode` Corefile directive
+	// ("ip", "signature", or "both"), so valonctl knows whether it needs to
+	// sign endpoint registration requests with the local identity key.
+	AuthMode string `yaml:"auth_mode,omitempty"`
+}
+
+// AliasPolicyConfig configures the validation.ReservedPolicy this
+// deployment enforces on alias names, letting an operator extend VALON's
+// own reserved namespace - or reserve a zone for another system, e.g.
+// "corp" - without recompiling.
+type AliasPolicyConfig struct {
+	// ReservedPrefixes, ReservedLabels, and ReservedZoneSuffixes are
+	// appended to validation.DefaultReservedPolicy's own lists, not a
+	// replacement for them: VALON's internal lan./nated./_wireguard._udp
+	// prefixes and the RFC 6761 special-use zone names stay reserved
+	// regardless of what's configured here.
+	ReservedPrefixes     []string `yaml:"reserved_prefixes,omitempty"`
+	ReservedLabels       []string `yaml:"reserved_labels,omitempty"`
+	ReservedZoneSuffixes []string `yaml:"reserved_zone_suffixes,omitempty"`
+}
+
+// ReservedPolicy builds the validation.ReservedPolicy this config section
+// describes, extending validation.DefaultReservedPolicy with whatever this
+// deployment added.
+func (a AliasPolicyConfig) ReservedPolicy() *validation.ReservedPolicy {
+	return &validation.ReservedPolicy{
+		Prefixes:     append(append([]string{}, validation.DefaultReservedPolicy.Prefixes...), a.ReservedPrefixes...),
+		Labels:       append(append([]string{}, validation.DefaultReservedPolicy.Labels...), a.ReservedLabels...),
+		ZoneSuffixes: append(append([]string{}, validation.DefaultReservedPolicy.ZoneSuffixes...), a.ReservedZoneSuffixes...),
+	}
 }
 
 // Load reads and parses the configuration file from the specified path.
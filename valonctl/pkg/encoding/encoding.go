@@ -1,6 +1,7 @@
 package encoding
 
 import (
+	"crypto/sha256"
 	"encoding/base32"
 	"encoding/base64"
 	"fmt"
@@ -10,56 +11,131 @@ import (
 // Base32 encoding without padding, lowercase (RFC 4648)
 var base32Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
 
-// LabelToPubkey converts DNS-safe label (base32) to WireGuard public key (base64).
+// base32Alphabet is the alphabet base32Encoding emits, lowercased - used by
+// DetectFormat to recognize both SchemeBase32Full labels and SchemeShortID
+// labels, which are also encoded with base32Encoding (see
+// PubkeyToLabelWith's SchemeShortID case).
+const base32Alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+// zBase32Alphabet is Zooko's z-base-32 alphabet: it drops visually
+// ambiguous characters (0/o, 1/l, etc.) and orders symbols so that common
+// typos still decode, making labels easier to read aloud or copy by hand
+// than standard Base32.
+const zBase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+var zBase32Encoding = base32.NewEncoding(zBase32Alphabet).WithPadding(base32.NoPadding)
+
+// shortIDBytes is the number of leading bytes of a pubkey's SHA-256 digest
+// used for the EncodingScheme "short ID" scheme, giving 16-character labels.
+// Collisions are possible at this length, which is why a short ID is only
+// ever resolved through the etcd secondary index rather than treated as a
+// pubkey encoding in its own right.
+const shortIDBytes = 10
+
+// EncodingScheme selects how PubkeyToLabelWith/LabelToPubkeyWith represent a
+// WireGuard public key as a DNS label.
+type EncodingScheme int
+
+const (
+	// SchemeBase32Full is the original full 52-character Base32 encoding of
+	// the raw 32-byte pubkey. It is lossless and is kept as the default for
+	// backward compatibility.
+	SchemeBase32Full EncodingScheme = iota
+
+	// SchemeZBase32 re-encodes the same 32 bytes with the z-base-32
+	// alphabet. Still 52 characters and still lossless, but easier to read
+	// and say aloud than standard Base32.
+	SchemeZBase32
+
+	// SchemeShortID encodes a truncated hash of the pubkey rather than the
+	// pubkey itself, producing a 16-character label at the cost of
+	// collisions: recovering the pubkey requires resolving the short ID
+	// through the etcd secondary index, not decoding the label directly.
+	SchemeShortID
+)
+
+// shortIDLength is the label length SchemeShortID produces: shortIDBytes
+// encoded as unpadded Base32 (8 chars per 5 bytes, rounded up).
+var shortIDLength = len(base32Encoding.EncodeToString(make([]byte, shortIDBytes)))
+
+// LabelToPubkey converts a DNS-safe Base32 label to a WireGuard public key
+// (base64). Equivalent to LabelToPubkeyWith(SchemeBase32Full, label).
 // DNS query: "mfrggzdfmztwq2lk.valon.internal"
 // → WireGuard pubkey: "abCD1234+/efGH5678=="
 func LabelToPubkey(label string) (string, error) {
-	if label == "" {
-		return "", fmt.Errorf("label cannot be empty")
-	}
-
-	// DNS labels are case-insensitive, convert to uppercase for Base32
-	label = strings.ToUpper(label)
-
-	// Decode from Base32
-	decoded, err := base32Encoding.DecodeString(label)
-	if err != nil {
-		return "", fmt.Errorf("invalid base32 label: %w", err)
-	}
-
-	// Encode to Base64 (standard WireGuard format)
-	pubkey := base64.StdEncoding.EncodeToString(decoded)
-	return pubkey, nil
+	return LabelToPubkeyWith(SchemeBase32Full, label)
 }
 
-// PubkeyToLabel converts WireGuard public key (base64) to DNS-safe label (base32).
+// PubkeyToLabel converts a WireGuard public key (base64) to a DNS-safe
+// Base32 label. Equivalent to PubkeyToLabelWith(SchemeBase32Full, pubkey).
 // WireGuard pubkey: "abCD1234+/efGH5678=="
 // → DNS label: "mfrggzdfmztwq2lk"
 func PubkeyToLabel(pubkey string) (string, error) {
+	return PubkeyToLabelWith(SchemeBase32Full, pubkey)
+}
+
+// PubkeyToLabelWith converts a WireGuard public key (base64) to a DNS label
+// using the given encoding scheme. SchemeShortID labels are not reversible
+// by LabelToPubkeyWith; the caller is responsible for registering the
+// short ID -> pubkey mapping in etcd (see client.EtcdClient.RegisterShortID)
+// so it can later be resolved via NormalizePubkey.
+func PubkeyToLabelWith(scheme EncodingScheme, pubkey string) (string, error) {
 	if pubkey == "" {
 		return "", fmt.Errorf("pubkey cannot be empty")
 	}
 
-	// Decode from Base64
 	decoded, err := base64.StdEncoding.DecodeString(pubkey)
 	if err != nil {
 		return "", fmt.Errorf("invalid base64 pubkey: %w", err)
 	}
-
-	// WireGuard public keys are always 32 bytes
 	if len(decoded) != 32 {
 		return "", fmt.Errorf("invalid pubkey length: %d bytes (expected 32)", len(decoded))
 	}
 
-	// Encode to Base32 without padding, lowercase
-	label := base32Encoding.EncodeToString(decoded)
-	label = strings.ToLower(label)
+	switch scheme {
+	case SchemeBase32Full:
+		return strings.ToLower(base32Encoding.EncodeToString(decoded)), nil
+	case SchemeZBase32:
+		return strings.ToLower(zBase32Encoding.EncodeToString(decoded)), nil
+	case SchemeShortID:
+		sum := sha256.Sum256(decoded)
+		return strings.ToLower(base32Encoding.EncodeToString(sum[:shortIDBytes])), nil
+	default:
+		return "", fmt.Errorf("unknown encoding scheme %d", scheme)
+	}
+}
+
+// LabelToPubkeyWith decodes a DNS label back to a WireGuard public key
+// (base64) for schemes that encode the pubkey itself. SchemeShortID is
+// not reversible this way since the label is a truncated hash; use
+// NormalizePubkey with an etcd-backed ShortIDResolver for those.
+func LabelToPubkeyWith(scheme EncodingScheme, label string) (string, error) {
+	if label == "" {
+		return "", fmt.Errorf("label cannot be empty")
+	}
 
-	return label, nil
+	switch scheme {
+	case SchemeBase32Full:
+		decoded, err := base32Encoding.DecodeString(strings.ToUpper(label))
+		if err != nil {
+			return "", fmt.Errorf("invalid base32 label: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(decoded), nil
+	case SchemeZBase32:
+		decoded, err := zBase32Encoding.DecodeString(strings.ToLower(label))
+		if err != nil {
+			return "", fmt.Errorf("invalid z-base-32 label: %w", err)
+		}
+		return base64.StdEncoding.EncodeToString(decoded), nil
+	case SchemeShortID:
+		return "", fmt.Errorf("short ID labels are not directly reversible; resolve %q through the etcd index instead", label)
+	default:
+		return "", fmt.Errorf("unknown encoding scheme %d", scheme)
+	}
 }
 
-// DetectFormat detects if input is a Base32 label or Base64 pubkey.
-// Returns "base32", "base64", or "unknown".
+// DetectFormat detects the format of input: a Base64 pubkey, a full-length
+// Base32 or z-base-32 label, a short ID, or "unknown".
 func DetectFormat(input string) string {
 	if input == "" {
 		return "unknown"
@@ -70,25 +146,57 @@ func DetectFormat(input string) string {
 		return "base64"
 	}
 
-	// Base32 (lowercase) contains only a-z, 2-7
-	if len(input) == 52 && !strings.ContainsAny(input, "+/=") {
-		return "base32"
+	lower := strings.ToLower(input)
+
+	switch len(lower) {
+	case shortIDLength:
+		// A short ID is base32Encoding.EncodeToString of a hash (see
+		// PubkeyToLabelWith's SchemeShortID case), not z-base-32.
+		if isAlphabetOnly(lower, base32Alphabet) {
+			return "shortid"
+		}
+	case 52:
+		// Both full schemes decode 32 bytes to 52 characters. Standard
+		// Base32's digit set (2-7) and z-base-32's (1,3-9) only partially
+		// overlap, so a digit unique to one alphabet disambiguates them;
+		// otherwise default to the original Base32 scheme.
+		if strings.ContainsAny(lower, "189") {
+			return "zbase32"
+		}
+		if isAlphabetOnly(lower, base32Alphabet) {
+			return "base32"
+		}
 	}
 
 	return "unknown"
 }
 
-// NormalizePubkey attempts to normalize any input to a Base64 WireGuard public key.
-// Accepts both Base32 labels and Base64 pubkeys.
-func NormalizePubkey(input string) (string, error) {
+func isAlphabetOnly(s, alphabet string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(alphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ShortIDResolver resolves a SchemeShortID label to the full Base64 pubkey
+// it was registered for, typically backed by the etcd secondary index at
+// /valon/shortids/<id> (see client.EtcdClient.RegisterShortID/ResolveShortID).
+type ShortIDResolver func(shortID string) (string, error)
+
+// NormalizePubkey attempts to normalize any input to a Base64 WireGuard
+// public key. Accepts Base64 pubkeys and Base32/z-base-32 labels directly;
+// a short ID is resolved via resolveShortID, which may be nil if short IDs
+// aren't in use (callers then get an "unknown format" error for short ID
+// input).
+func NormalizePubkey(input string, resolveShortID ShortIDResolver) (string, error) {
 	if input == "" {
 		return "", fmt.Errorf("input cannot be empty")
 	}
 
-	format := DetectFormat(input)
-	switch format {
+	switch DetectFormat(input) {
 	case "base64":
-		// Validate by decoding
 		decoded, err := base64.StdEncoding.DecodeString(input)
 		if err != nil {
 			return "", fmt.Errorf("invalid base64 format: %w", err)
@@ -99,10 +207,18 @@ func NormalizePubkey(input string) (string, error) {
 		return input, nil
 
 	case "base32":
-		// Convert to base64
-		return LabelToPubkey(input)
+		return LabelToPubkeyWith(SchemeBase32Full, input)
+
+	case "zbase32":
+		return LabelToPubkeyWith(SchemeZBase32, input)
+
+	case "shortid":
+		if resolveShortID == nil {
+			return "", fmt.Errorf("unknown format: %q looks like a short ID but no resolver was configured", input)
+		}
+		return resolveShortID(input)
 
 	default:
-		return "", fmt.Errorf("unknown format: expected base64 pubkey or base32 label")
+		return "", fmt.Errorf("unknown format: expected base64 pubkey, base32/z-base-32 label, or short ID")
 	}
 }
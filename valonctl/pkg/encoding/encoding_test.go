@@ -0,0 +1,25 @@
+package encoding
+
+import "testing"
+
+func TestDetectFormatShortID(t *testing.T) {
+	pubkey := "4NnAv7VCRtjwVTq6i6G6ApW6Oa/SYWAo2SiNWo0GMFA="
+
+	shortID, err := PubkeyToLabelWith(SchemeShortID, pubkey)
+	if err != nil {
+		t.Fatalf("PubkeyToLabelWith(SchemeShortID) error: %v", err)
+	}
+
+	if got := DetectFormat(shortID); got != "shortid" {
+		t.Errorf("DetectFormat(%q) = %q, want %q", shortID, got, "shortid")
+	}
+
+	if _, err := NormalizePubkey(shortID, func(id string) (string, error) {
+		if id != shortID {
+			t.Errorf("resolver called with %q, want %q", id, shortID)
+		}
+		return pubkey, nil
+	}); err != nil {
+		t.Errorf("NormalizePubkey(%q) error: %v", shortID, err)
+	}
+}
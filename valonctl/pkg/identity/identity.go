@@ -0,0 +1,72 @@
+// Package identity manages the Ed25519 identity keypair used to sign DDNS
+// endpoint registration requests, so a peer can prove ownership of its
+// WireGuard public key without relying on its current source IP (see
+// coredns-plugin/valon's signature DDNS auth mode).
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultKeyPath is where a peer's identity private key is stored by default.
+const DefaultKeyPath = "/etc/valon/identity.key"
+
+// KeyPair holds an Ed25519 identity key used to sign DDNS requests.
+type KeyPair struct {
+	Public  ed25519.PublicKey
+	Private ed25519.PrivateKey
+}
+
+// Generate creates a new random Ed25519 identity keypair.
+func Generate() (*KeyPair, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity key: %w", err)
+	}
+	return &KeyPair{Public: pub, Private: priv}, nil
+}
+
+// PublicBase64 returns the public key in the encoding stored in etcd under
+// /valon/peers/<pubkey>/identity_pub.
+func (k *KeyPair) PublicBase64() string {
+	return base64.StdEncoding.EncodeToString(k.Public)
+}
+
+// Save writes the private key to path, creating parent directories as
+// needed. The file is written with 0600 permissions since it is a secret.
+func (k *KeyPair) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, k.Private, 0600); err != nil {
+		return fmt.Errorf("failed to write identity key to %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load reads a private key previously written by Save and reconstructs the
+// keypair (Ed25519 private keys embed the public key).
+func Load(path string) (*KeyPair, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity key %s: %w", path, err)
+	}
+	if len(raw) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid identity key size in %s: %d bytes", path, len(raw))
+	}
+
+	priv := ed25519.PrivateKey(raw)
+	return &KeyPair{Public: priv.Public().(ed25519.PublicKey), Private: priv}, nil
+}
+
+// Sign signs message and returns the signature base64-encoded, ready to be
+// placed in a DDNSEndpointRequest's Signature field.
+func (k *KeyPair) Sign(message []byte) string {
+	sig := ed25519.Sign(k.Private, message)
+	return base64.StdEncoding.EncodeToString(sig)
+}
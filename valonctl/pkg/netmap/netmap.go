@@ -0,0 +1,380 @@
+// Package netmap implements the long-poll "map poll" distribution channel
+// between valonctl and the valon CoreDNS plugin: a Coordinator turns an
+// etcd watch on /valon/peers/ into a bounded log of Deltas indexed by
+// etcd revision ("version"), and serves it over HTTP as
+// GET /netmap?since=<version> with long-poll semantics, so multiple
+// CoreDNS instances can share one coalesced upstream view instead of each
+// holding its own etcd watch.
+//
+// The wire types here (DeltaOp, Peer, Delta, Response) mirror
+// coredns-plugin/valon's netmap.go byte-for-byte - the two modules never
+// import each other (see ddns.go's DDNSEndpointRequest/EndpointUpdate for
+// the existing precedent of this kind of duplication), so the JSON
+// contract is kept in sync by hand.
+package netmap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/pabotesu/valon/valonctl/pkg/client"
+)
+
+// keepaliveInterval is how long ServeHTTP holds a long-poll request open
+// waiting for the version to advance before responding at the caller's
+// current version with no deltas, so the connection doesn't sit open
+// indefinitely and the client learns the coordinator is still alive.
+const keepaliveInterval = 25 * time.Second
+
+// maxLogEntries bounds how many past deltas the coordinator retains for
+// incremental replay. A since older than the oldest retained entry (or
+// older than the coordinator's own startup baseline) gets a Compacted
+// snapshot response instead.
+const maxLogEntries = 1000
+
+// DeltaOp identifies the kind of change a Delta represents.
+type DeltaOp string
+
+const (
+	OpPut    DeltaOp = "put"
+	OpDelete DeltaOp = "delete"
+)
+
+// Peer is the wire representation of a peer record in a Delta or Snapshot.
+type Peer struct {
+	Pubkey        string `json:"pubkey"`
+	Alias         string `json:"alias,omitempty"`
+	WgIP          string `json:"wg_ip,omitempty"`
+	WgIPv6        string `json:"wg_ip6,omitempty"`
+	LANEndpoint   string `json:"lan_endpoint,omitempty"`
+	LANEndpointV6 string `json:"lan_endpoint6,omitempty"`
+	NATEndpoint   string `json:"nat_endpoint,omitempty"`
+	NATEndpointV6 string `json:"nat_endpoint6,omitempty"`
+	StunEndpoint  string `json:"stun_endpoint,omitempty"`
+	NATType       string `json:"nat_type,omitempty"`
+	Role          string `json:"role,omitempty"`
+	RelayedVia    string `json:"relayed_via,omitempty"`
+	IsStatic      bool   `json:"is_static,omitempty"`
+	Endpoint      string `json:"endpoint,omitempty"`
+}
+
+// Delta is a single add/update/remove, as streamed by GET /netmap.
+type Delta struct {
+	Op     DeltaOp `json:"op"`
+	Pubkey string  `json:"pubkey"`
+	Peer   *Peer   `json:"peer,omitempty"` // nil when Op is OpDelete
+}
+
+// Response is the body of a GET /netmap?since=<version> response.
+type Response struct {
+	Version int64 `json:"version"`
+
+	// Compacted means since was older than the coordinator's retained
+	// delta log (including a brand new client polling with since=0);
+	// Snapshot replaces the caller's cache wholesale and Deltas is empty.
+	Compacted bool    `json:"compacted,omitempty"`
+	Deltas    []Delta `json:"deltas,omitempty"`
+	Snapshot  []*Peer `json:"snapshot,omitempty"`
+}
+
+// VersionTrailer and HashTrailer are the HTTP response trailers ServeHTTP
+// sets, so a client can tell a truncated or tampered-with body from a
+// legitimately empty keepalive response.
+const (
+	VersionTrailer = "X-Netmap-Version"
+	HashTrailer    = "X-Netmap-Hash"
+)
+
+// deltaHash mirrors coredns-plugin/valon's deltaHash byte-for-byte: a
+// sha256 over each delta's op, pubkey, and JSON-encoded peer, in order.
+func deltaHash(deltas []Delta) string {
+	h := sha256.New()
+	for _, d := range deltas {
+		fmt.Fprintf(h, "%s:%s:", d.Op, d.Pubkey)
+		if d.Peer != nil {
+			b, _ := json.Marshal(d.Peer)
+			h.Write(b)
+		}
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// logEntry is one retained change in Coordinator's delta log.
+type logEntry struct {
+	rev   int64
+	delta Delta
+}
+
+// Coordinator serves the long-poll GET /netmap?since=<version> endpoint,
+// backed by an etcd watch on /valon/peers/. It is the single place
+// valonctl could enforce authorization on who receives which peers, should
+// that be added later - every caller currently sees the same view.
+type Coordinator struct {
+	etcd *client.EtcdClient
+
+	mu      sync.Mutex
+	version int64
+	log     []logEntry
+	waiters map[chan struct{}]struct{}
+}
+
+// NewCoordinator creates a Coordinator backed by etcd. Call Run to start
+// consuming etcd's peer watch before serving requests (ServeHTTP works
+// before Run starts producing changes, but with nothing recorded yet every
+// poll waits out the keepalive and returns version 0 with no deltas).
+func NewCoordinator(etcd *client.EtcdClient) *Coordinator {
+	return &Coordinator{
+		etcd:    etcd,
+		waiters: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Run establishes a consistent list-then-watch baseline and then feeds
+// every subsequent /valon/peers/ change into the delta log until ctx is
+// canceled or the etcd watch fails. A watch failure (including the etcd
+// watch itself being compacted, which - unlike a client's since being
+// compacted out of our own log - this does not attempt to recover from)
+// is returned to the caller, which should restart the coordinator; callers
+// reconnect via ServeHTTP long-polls exactly as they would across any
+// other coordinator restart.
+func (c *Coordinator) Run(ctx context.Context) error {
+	rev, err := c.etcd.CurrentRevision(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to establish baseline revision: %w", err)
+	}
+
+	c.mu.Lock()
+	c.version = rev
+	c.mu.Unlock()
+	log.Printf("[netmap] coordinator started at baseline version %d", rev)
+
+	watchCh := c.etcd.WatchPeersFromRevision(ctx, rev+1)
+	for {
+		select {
+		case resp, ok := <-watchCh:
+			if !ok {
+				return fmt.Errorf("etcd watch channel closed")
+			}
+			if err := resp.Err(); err != nil {
+				return fmt.Errorf("etcd watch error: %w", err)
+			}
+			for _, ev := range resp.Events {
+				c.handleEvent(ctx, ev)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// peersKeyPrefix is /valon/peers/, matching client.EtcdClient's internal
+// key layout (see client.SplitPeerKey).
+var peersKeyPrefix = path.Join(client.EtcdKeyPrefix, "peers") + "/"
+
+// handleEvent turns a single raw etcd event into a Delta and appends it to
+// the log, reloading the affected peer's full record rather than trying to
+// patch just the one changed field - mirroring coredns-plugin/valon's
+// EtcdStore.emitEvent, for the same reason: the watch stream is low-volume
+// enough that the extra Get is cheap, and this keeps Delta.Peer always a
+// complete, consistent record.
+func (c *Coordinator) handleEvent(ctx context.Context, ev *clientv3.Event) {
+	relKey := strings.TrimPrefix(string(ev.Kv.Key), peersKeyPrefix)
+	pubkey, field, ok := client.SplitPeerKey(relKey)
+	if !ok {
+		return
+	}
+
+	var delta Delta
+	if ev.Type == clientv3.EventTypeDelete {
+		// Only the deletion of the primary key means the whole peer is
+		// gone; deletion of a single field key is rare and not otherwise
+		// meaningful here.
+		if field != "wg_ip" {
+			return
+		}
+		delta = Delta{Op: OpDelete, Pubkey: pubkey}
+	} else {
+		peer, err := c.etcd.GetPeer(ctx, pubkey)
+		if err != nil {
+			log.Printf("[netmap] failed to reload peer %s after watch event: %v", pubkey, err)
+			return
+		}
+		if peer == nil {
+			return
+		}
+		delta = Delta{Op: OpPut, Pubkey: pubkey, Peer: peerToWire(peer)}
+	}
+
+	c.appendDelta(ev.Kv.ModRevision, delta)
+}
+
+// appendDelta records a delta at revision rev, bumps the current version,
+// trims the log to maxLogEntries, and wakes any long-polling ServeHTTP
+// callers.
+func (c *Coordinator) appendDelta(rev int64, d Delta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if rev <= c.version {
+		// Already observed (or stale relative to what we've already
+		// folded in); ignore rather than rewind the version.
+		return
+	}
+	c.version = rev
+	c.log = append(c.log, logEntry{rev: rev, delta: d})
+	if len(c.log) > maxLogEntries {
+		c.log = c.log[len(c.log)-maxLogEntries:]
+	}
+
+	for ch := range c.waiters {
+		close(ch)
+	}
+	c.waiters = make(map[chan struct{}]struct{})
+}
+
+// poll computes the Response for a single since request against the
+// current version and log, without blocking.
+func (c *Coordinator) poll(ctx context.Context, since int64) (Response, error) {
+	c.mu.Lock()
+	version := c.version
+	if since >= version {
+		c.mu.Unlock()
+		return Response{Version: version}, nil
+	}
+	if len(c.log) > 0 && since >= c.log[0].rev-1 {
+		out := make([]Delta, 0, len(c.log))
+		for _, e := range c.log {
+			if e.rev > since {
+				out = append(out, e.delta)
+			}
+		}
+		c.mu.Unlock()
+		return Response{Version: version, Deltas: out}, nil
+	}
+	c.mu.Unlock()
+
+	return c.snapshotResponse(ctx)
+}
+
+// snapshotResponse lists every peer currently in etcd and returns it as a
+// Compacted Response at the coordinator's current version.
+func (c *Coordinator) snapshotResponse(ctx context.Context) (Response, error) {
+	peers, err := c.etcd.ListPeers(ctx)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to list peers for snapshot: %w", err)
+	}
+
+	wire := make([]*Peer, 0, len(peers))
+	for _, p := range peers {
+		wire = append(wire, peerToWire(p))
+	}
+
+	c.mu.Lock()
+	version := c.version
+	c.mu.Unlock()
+
+	return Response{Version: version, Compacted: true, Snapshot: wire}, nil
+}
+
+// waitForChange blocks until the version advances, timeout elapses, or ctx
+// is canceled, whichever comes first.
+func (c *Coordinator) waitForChange(ctx context.Context, timeout time.Duration) {
+	c.mu.Lock()
+	ch := make(chan struct{})
+	c.waiters[ch] = struct{}{}
+	c.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	c.mu.Lock()
+	delete(c.waiters, ch)
+	c.mu.Unlock()
+}
+
+// ServeHTTP implements GET /netmap?since=<version>: it answers
+// immediately if there's already something new to report, otherwise holds
+// the request open for up to keepaliveInterval waiting for a change
+// before responding with the caller's unchanged version.
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since := int64(0)
+	if s := r.URL.Query().Get("since"); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		since = v
+	}
+
+	resp, err := c.poll(r.Context(), since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !resp.Compacted && len(resp.Deltas) == 0 {
+		c.waitForChange(r.Context(), keepaliveInterval)
+		resp, err = c.poll(r.Context(), since)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Trailer", VersionTrailer+", "+HashTrailer)
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("[netmap] failed to encode response: %v", err)
+	}
+
+	w.Header().Set(VersionTrailer, strconv.FormatInt(resp.Version, 10))
+	w.Header().Set(HashTrailer, deltaHash(resp.Deltas))
+}
+
+// peerToWire converts a client.PeerInfo into the wire-format Peer a Delta
+// or Snapshot carries.
+func peerToWire(p *client.PeerInfo) *Peer {
+	return &Peer{
+		Pubkey:        p.Pubkey,
+		Alias:         p.Alias,
+		WgIP:          p.IP,
+		WgIPv6:        p.IPv6,
+		LANEndpoint:   p.LANEndpoint,
+		LANEndpointV6: p.LANEndpointV6,
+		NATEndpoint:   p.NATEndpoint,
+		NATEndpointV6: p.NATEndpointV6,
+		StunEndpoint:  p.StunEndpoint,
+		NATType:       p.NATType,
+		Role:          p.Role,
+		RelayedVia:    p.RelayMarker,
+		IsStatic:      p.IsStatic,
+		Endpoint:      p.Endpoint,
+	}
+}
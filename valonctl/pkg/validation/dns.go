@@ -23,49 +23,31 @@ var (
 	dnsLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
 )
 
-// ValidateAlias validates an alias name for DNS compatibility.
-// Returns error if the alias is invalid.
-func ValidateAlias(alias string) error {
-	if alias == "" {
-		return fmt.Errorf("alias cannot be empty")
+// IsDNS1123Label returns every reason value isn't a valid DNS-1123 label
+// (RFC 1123), or nil if it is one. It returns a slice rather than stopping
+// at the first problem so a caller validating a whole spec (see
+// ValidateAliasSpec) can report every violation on a field at once.
+func IsDNS1123Label(value string) []string {
+	var errs []string
+	if len(value) > MaxLabelLength {
+		errs = append(errs, fmt.Sprintf("must be no more than %d characters", MaxLabelLength))
 	}
-
-	// Convert to lowercase for validation
-	alias = strings.ToLower(alias)
-
-	// Check maximum length (user-friendly limit)
-	if len(alias) > MaxAliasLength {
-		return fmt.Errorf("alias too long: %d characters (max %d)", len(alias), MaxAliasLength)
+	if !dnsLabelRegex.MatchString(value) {
+		errs = append(errs, "must consist of lowercase alphanumeric characters or '-', and must start and end with an alphanumeric character")
 	}
-
-	// Check DNS RFC limit
-	if len(alias) > MaxLabelLength {
-		return fmt.Errorf("alias exceeds DNS label limit: %d characters (max %d)", len(alias), MaxLabelLength)
-	}
-
-	// Validate DNS label format
-	if !dnsLabelRegex.MatchString(alias) {
-		return fmt.Errorf("invalid alias format: must contain only lowercase letters, numbers, and hyphens (not at start/end)")
-	}
-
-	// Additional checks
-	if strings.HasPrefix(alias, "-") || strings.HasSuffix(alias, "-") {
-		return fmt.Errorf("alias cannot start or end with a hyphen")
-	}
-
-	if strings.Contains(alias, "--") {
-		return fmt.Errorf("alias cannot contain consecutive hyphens")
-	}
-
-	// Reserved prefixes (used by VALON internally)
-	reservedPrefixes := []string{"lan", "nated", "_wireguard", "_udp"}
-	for _, prefix := range reservedPrefixes {
-		if strings.HasPrefix(alias, prefix) {
-			return fmt.Errorf("alias cannot start with reserved prefix: %s", prefix)
-		}
+	// The "xn--" prefix of a punycode A-label is the one allowed "--".
+	if strings.Contains(strings.TrimPrefix(value, "xn--"), "--") {
+		errs = append(errs, "must not contain consecutive hyphens")
 	}
+	return errs
+}
 
-	return nil
+// ValidateAlias validates an alias name for DNS compatibility against
+// DefaultReservedPolicy. Returns error if the alias is invalid. A
+// deployment that's loaded its own ReservedPolicy from config should call
+// ValidateAliasWithPolicy directly instead (see policy.go).
+func ValidateAlias(alias string) error {
+	return ValidateAliasWithPolicy(alias, DefaultReservedPolicy)
 }
 
 // ValidateFQDN validates the total length of a fully qualified domain name.
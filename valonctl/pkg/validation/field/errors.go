@@ -0,0 +1,63 @@
+package field
+
+import "fmt"
+
+// ErrorType classifies what went wrong with a field's value.
+type ErrorType string
+
+const (
+	// ErrorTypeRequired means a required field was empty or unset.
+	ErrorTypeRequired ErrorType = "FieldValueRequired"
+	// ErrorTypeInvalid means a field's value failed validation.
+	ErrorTypeInvalid ErrorType = "FieldValueInvalid"
+)
+
+// Error is a single field-level validation failure, keyed by the Path of
+// the field it applies to so a caller can render it as e.g. "spec.alias:
+// must be no more than 32 characters".
+type Error struct {
+	Type     ErrorType
+	Field    string
+	BadValue interface{}
+	Detail   string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Detail)
+}
+
+// Required returns an Error indicating that field has no value but a value
+// is required.
+func Required(field *Path, detail string) *Error {
+	return &Error{Type: ErrorTypeRequired, Field: field.String(), Detail: detail}
+}
+
+// Invalid returns an Error indicating that field has an invalid value.
+func Invalid(field *Path, value interface{}, detail string) *Error {
+	return &Error{Type: ErrorTypeInvalid, Field: field.String(), BadValue: value, Detail: detail}
+}
+
+// ErrorList is a collection of field Errors, accumulated across a whole
+// validation pass instead of returning at the first one.
+type ErrorList []*Error
+
+// Error joins every error in the list into one message, so an ErrorList can
+// be returned as a plain error where a caller doesn't care to walk it field
+// by field.
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return ""
+	case 1:
+		return list[0].Error()
+	}
+	msg := fmt.Sprintf("%d validation errors: ", len(list))
+	for i, e := range list {
+		if i > 0 {
+			msg += "; "
+		}
+		msg += e.Error()
+	}
+	return msg
+}
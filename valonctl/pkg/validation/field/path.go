@@ -0,0 +1,78 @@
+// Package field provides a field.Path/field.ErrorList pair, modeled on
+// k8s.io/apimachinery/pkg/util/validation/field, for validators that need to
+// report every problem found across a nested value in one pass - keyed by a
+// JSON/YAML-style locator like "spec.tags[2].key" - rather than failing out
+// with a single error on the first violation.
+package field
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Path represents the path from some root to a particular field, built up
+// one Child/Index call at a time as a validator descends into a value.
+type Path struct {
+	name   string // the name of this field or "" if this is an index
+	index  string // if the field is an array, the value of this index
+	parent *Path  // nil if this is the root element
+}
+
+// NewPath creates a root Path with the given name, plus any child names
+// given in sequence, e.g. NewPath("spec", "alias") is the same as
+// NewPath("spec").Child("alias").
+func NewPath(name string, moreNames ...string) *Path {
+	r := &Path{name: name}
+	for _, anotherName := range moreNames {
+		r = &Path{name: anotherName, parent: r}
+	}
+	return r
+}
+
+// Child adds a new name to the path, returning the new, longer path.
+func (p *Path) Child(name string, moreNames ...string) *Path {
+	r := NewPath(name, moreNames...)
+	r.parent = p
+	return r
+}
+
+// Index indicates that the path is to a numeric index, as when iterating
+// over a slice - e.g. p.Child("tags").Index(2) renders as "tags[2]".
+func (p *Path) Index(index int) *Path {
+	return &Path{index: strconv.Itoa(index), parent: p}
+}
+
+// Key indicates that the path is to a map entry, as when iterating over a
+// map - e.g. p.Child("tags").Key("env") renders as "tags[env]".
+func (p *Path) Key(key string) *Path {
+	return &Path{index: key, parent: p}
+}
+
+// String renders the path as a dotted locator, e.g. "spec.tags[2].key".
+func (p *Path) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+
+	var elems []*Path
+	for cur := p; cur != nil; cur = cur.parent {
+		elems = append(elems, cur)
+	}
+
+	var b strings.Builder
+	for i := len(elems) - 1; i >= 0; i-- {
+		e := elems[i]
+		switch {
+		case e.name != "":
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(e.name)
+		case e.index != "":
+			b.WriteByte('[')
+			b.WriteString(e.index)
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
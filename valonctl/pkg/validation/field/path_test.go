@@ -0,0 +1,23 @@
+package field
+
+import "testing"
+
+func TestPathString(t *testing.T) {
+	tests := []struct {
+		name string
+		path *Path
+		want string
+	}{
+		{"root", NewPath("spec"), "spec"},
+		{"child", NewPath("spec").Child("alias"), "spec.alias"},
+		{"indexed child", NewPath("spec").Child("tags").Index(2).Child("key"), "spec.tags[2].key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.path.String(); got != tt.want {
+				t.Errorf("Path.String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ValidateUnicodeAlias validates a Unicode alias (e.g. "café", "東京") by
+// converting it to its DNS-safe A-label form with the IDNA Lookup profile -
+// the same profile a conforming resolver applies when it encounters a U-label
+// on the wire - and then running it through ValidateAlias. asciiLabel is the
+// punycode-encoded form actually suitable for registration; callers should
+// store and query that, not the original Unicode input. The 63-byte label
+// limit ValidateAlias enforces is checked against this encoded form, which is
+// what matters on the wire, not the rune count of the input.
+func ValidateUnicodeAlias(input string) (asciiLabel string, warnings []string, err error) {
+	if strings.HasPrefix(strings.ToLower(input), "xn--") {
+		return "", nil, fmt.Errorf("alias %q is already punycode-encoded: pass the decoded unicode form, not xn--", input)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid unicode alias %q: %w", input, err)
+	}
+
+	if ascii != input {
+		warnings = append(warnings, "converted from unicode via punycode")
+	}
+
+	if err := ValidateAlias(ascii); err != nil {
+		return "", warnings, err
+	}
+
+	return ascii, warnings, nil
+}
+
+// SanitizeUnicodeAlias is SanitizeAlias's companion for Unicode input: it
+// NFC-normalizes input, converts it to its punycode A-label via the IDNA
+// Lookup profile, and then runs the result through SanitizeAlias. Unlike
+// ValidateUnicodeAlias, it never fails outright - if input doesn't convert
+// cleanly (e.g. it violates the IDNA bidi rule), SanitizeUnicodeAlias falls
+// back to sanitizing the normalized input as plain text, the same as
+// SanitizeAlias would for any other alias containing characters outside
+// a-z0-9-.
+func SanitizeUnicodeAlias(input string) (string, []string) {
+	var warnings []string
+
+	normalized := norm.NFC.String(input)
+	if normalized != input {
+		warnings = append(warnings, "normalized to NFC")
+	}
+
+	ascii, err := idna.Lookup.ToASCII(normalized)
+	switch {
+	case err != nil:
+		ascii = normalized
+	case ascii != normalized:
+		warnings = append(warnings, "converted from unicode via punycode")
+	}
+
+	sanitized, sanitizeWarnings := SanitizeAlias(ascii)
+	warnings = append(warnings, sanitizeWarnings...)
+	return sanitized, warnings
+}
@@ -0,0 +1,35 @@
+package validation
+
+import "testing"
+
+func TestValidateUnicodeAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		alias   string
+		wantErr bool
+	}{
+		{"ascii passthrough", "alice", false},
+		{"accented latin", "café", false},
+		{"already punycode", "xn--caf-dma", true},
+		{"reserved prefix after encoding", "lan", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ValidateUnicodeAlias(tt.alias)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUnicodeAlias(%q) error = %v, wantErr %v", tt.alias, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSanitizeUnicodeAlias(t *testing.T) {
+	sanitized, warnings := SanitizeUnicodeAlias("café")
+	if sanitized == "café" {
+		t.Errorf("SanitizeUnicodeAlias(%q) did not convert to ascii, got %q", "café", sanitized)
+	}
+	if len(warnings) == 0 {
+		t.Errorf("SanitizeUnicodeAlias(%q) produced no warnings for a unicode conversion", "café")
+	}
+}
@@ -0,0 +1,108 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReservedPolicy governs which alias names and zones are treated as
+// reserved, so a deployment can extend VALON's own reserved namespace - or
+// reserve a zone for another system entirely (e.g. "corp") - via config
+// instead of a code change.
+type ReservedPolicy struct {
+	// Prefixes reserves any alias starting with one of these strings (e.g.
+	// "k8s-" to keep a naming scheme free for another system). Matched
+	// case-insensitively, like the rest of alias validation.
+	Prefixes []string
+
+	// Labels reserves these exact alias values.
+	Labels []string
+
+	// ZoneSuffixes reserves any zone equal to, or a DNS subdomain of, one
+	// of these names - checked by ValidateAliasSpec against spec.Zone.
+	ZoneSuffixes []string
+}
+
+// DefaultReservedPolicy is the ReservedPolicy ValidateAlias uses when a
+// caller doesn't supply its own: VALON's own internal label namespace
+// (lan./nated. address queries, the _wireguard._udp SRV name - see
+// handleA/handleSRV in coredns-plugin/valon/handler.go), plus the RFC 6761
+// special-use names as reserved zone suffixes, since none of those are ever
+// safe to delegate a VALON zone under.
+//
+// It's a package-level var, not a function, so a deployment can override or
+// extend it at init time (e.g. append an in-house reserved prefix) without
+// plumbing a policy through every ValidateAlias call site. Config-driven
+// overrides, where an operator wants this sourced from a config file
+// instead of a recompile, should build their own *ReservedPolicy (see
+// valonctl/pkg/config's AliasPolicyConfig) and call ValidateAliasWithPolicy
+// directly rather than mutating this var.
+var DefaultReservedPolicy = &ReservedPolicy{
+	Prefixes:     []string{"lan", "nated", "_wireguard", "_udp"},
+	ZoneSuffixes: []string{"localhost", "local", "example", "invalid", "test"},
+}
+
+// IsReservedPrefix returns a reason alias starts with one of p's reserved
+// prefixes, or nil if it doesn't.
+func IsReservedPrefix(alias string, p *ReservedPolicy) []string {
+	for _, prefix := range p.Prefixes {
+		if strings.HasPrefix(alias, strings.ToLower(prefix)) {
+			return []string{fmt.Sprintf("must not start with reserved prefix %q", prefix)}
+		}
+	}
+	return nil
+}
+
+// IsReservedLabel returns a reason alias exactly matches one of p's
+// reserved labels, or nil if it doesn't.
+func IsReservedLabel(alias string, p *ReservedPolicy) []string {
+	for _, label := range p.Labels {
+		if alias == strings.ToLower(label) {
+			return []string{fmt.Sprintf("%q is a reserved name", label)}
+		}
+	}
+	return nil
+}
+
+// IsReservedZone returns a reason zone is, or falls under, one of p's
+// reserved zone suffixes, or nil if it doesn't.
+func IsReservedZone(zone string, p *ReservedPolicy) []string {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	for _, suffix := range p.ZoneSuffixes {
+		suffix = strings.ToLower(suffix)
+		if zone == suffix || strings.HasSuffix(zone, "."+suffix) {
+			return []string{fmt.Sprintf("zone must not be, or fall under, reserved name %q", suffix)}
+		}
+	}
+	return nil
+}
+
+// ValidateAliasWithPolicy is ValidateAlias against an explicit
+// ReservedPolicy instead of DefaultReservedPolicy, for a deployment that's
+// loaded its own policy from config (see valonctl/pkg/config's
+// AliasPolicyConfig).
+func ValidateAliasWithPolicy(alias string, p *ReservedPolicy) error {
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+
+	alias = strings.ToLower(alias)
+
+	if len(alias) > MaxAliasLength {
+		return fmt.Errorf("alias too long: %d characters (max %d)", len(alias), MaxAliasLength)
+	}
+
+	if errs := IsDNS1123Label(alias); len(errs) > 0 {
+		return fmt.Errorf("invalid alias %q: %s", alias, strings.Join(errs, "; "))
+	}
+
+	if errs := IsReservedPrefix(alias, p); len(errs) > 0 {
+		return fmt.Errorf("invalid alias %q: %s", alias, strings.Join(errs, "; "))
+	}
+
+	if errs := IsReservedLabel(alias, p); len(errs) > 0 {
+		return fmt.Errorf("invalid alias %q: %s", alias, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
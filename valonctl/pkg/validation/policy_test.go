@@ -0,0 +1,40 @@
+package validation
+
+import "testing"
+
+func TestValidateAliasWithPolicy(t *testing.T) {
+	policy := &ReservedPolicy{
+		Prefixes: []string{"k8s-"},
+		Labels:   []string{"admin"},
+	}
+
+	tests := []struct {
+		name    string
+		alias   string
+		wantErr bool
+	}{
+		{"unrelated alias", "alice", false},
+		{"custom reserved prefix", "k8s-node1", true},
+		{"custom reserved label", "admin", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAliasWithPolicy(tt.alias, policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAliasWithPolicy(%q) error = %v, wantErr %v", tt.alias, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsReservedZone(t *testing.T) {
+	policy := &ReservedPolicy{ZoneSuffixes: []string{"corp"}}
+
+	if errs := IsReservedZone("example.corp.", policy); len(errs) == 0 {
+		t.Errorf("IsReservedZone(%q) = nil, want a violation", "example.corp.")
+	}
+	if errs := IsReservedZone("valon.internal.", policy); len(errs) != 0 {
+		t.Errorf("IsReservedZone(%q) = %v, want no violation", "valon.internal.", errs)
+	}
+}
@@ -0,0 +1,144 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pabotesu/valon/valonctl/pkg/validation/field"
+)
+
+const (
+	// maxQualifiedNameLength is the max length of a qualified name's name
+	// part (and of a label value, which uses the same grammar).
+	maxQualifiedNameLength = 63
+
+	// MaxTagCount bounds how many tags an AliasSpec may carry.
+	MaxTagCount = 64
+	// MaxTagBytes bounds the total key+value bytes across all of an
+	// AliasSpec's tags.
+	MaxTagBytes = 4096
+)
+
+// qualifiedNameRegex matches the name part of a Kubernetes-style qualified
+// name, and (since they share a grammar) a label value:
+// [A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?
+var qualifiedNameRegex = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// dns1123SubdomainRegex matches a DNS-1123 subdomain: one or more
+// DNS-1123 labels joined by '.', e.g. "team.example.com".
+var dns1123SubdomainRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// IsDNS1123Subdomain returns every reason value isn't a valid DNS-1123
+// subdomain (RFC 1123): up to 253 characters, one or more DNS labels
+// joined by '.'. Used as the prefix half of a qualified name.
+func IsDNS1123Subdomain(value string) []string {
+	var errs []string
+	if len(value) > MaxFQDNLength {
+		errs = append(errs, fmt.Sprintf("must be no more than %d characters", MaxFQDNLength))
+	}
+	if !dns1123SubdomainRegex.MatchString(value) {
+		errs = append(errs, "must consist of lowercase alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character")
+	}
+	return errs
+}
+
+// ValidateQualifiedName returns every reason value isn't a valid
+// Kubernetes-style qualified name: an optional DNS-1123 subdomain prefix,
+// separated from the name by '/', where the name is
+// [A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])? up to 63 characters and the
+// prefix is a DNS-1123 subdomain up to 253 characters (e.g. "env" or
+// "team.example.com/owner").
+func ValidateQualifiedName(value string) []string {
+	parts := strings.Split(value, "/")
+
+	var prefix, name string
+	switch len(parts) {
+	case 1:
+		name = parts[0]
+	case 2:
+		prefix, name = parts[0], parts[1]
+	default:
+		return []string{"must consist of an optional DNS subdomain prefix and a name, separated by at most one '/'"}
+	}
+
+	var errs []string
+	if prefix != "" {
+		for _, e := range IsDNS1123Subdomain(prefix) {
+			errs = append(errs, fmt.Sprintf("prefix part %s", e))
+		}
+	} else if len(parts) == 2 {
+		errs = append(errs, "prefix part must be non-empty")
+	}
+
+	if name == "" {
+		errs = append(errs, "name part must be non-empty")
+	} else {
+		if len(name) > maxQualifiedNameLength {
+			errs = append(errs, fmt.Sprintf("name part must be no more than %d characters", maxQualifiedNameLength))
+		}
+		if !qualifiedNameRegex.MatchString(name) {
+			errs = append(errs, "name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character")
+		}
+	}
+
+	return errs
+}
+
+// ValidateLabelValue returns every reason value isn't a valid label value:
+// empty, or up to 63 characters matching the same grammar as a qualified
+// name's name part.
+func ValidateLabelValue(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var errs []string
+	if len(value) > maxQualifiedNameLength {
+		errs = append(errs, fmt.Sprintf("must be no more than %d characters", maxQualifiedNameLength))
+	}
+	if !qualifiedNameRegex.MatchString(value) {
+		errs = append(errs, "must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character")
+	}
+	return errs
+}
+
+// ValidateTags validates an AliasSpec's Tags map: every key as a qualified
+// name, every value as a label value, and the map as a whole against
+// MaxTagCount/MaxTagBytes so it can't be used to smuggle unbounded
+// metadata into etcd. fldPath is the path to the Tags field itself (e.g.
+// field.NewPath("spec", "tags")); per-entry errors are keyed under
+// fldPath.Key(<tag key>).
+func ValidateTags(tags map[string]string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(tags) > MaxTagCount {
+		allErrs = append(allErrs, field.Invalid(fldPath, len(tags), fmt.Sprintf("must not have more than %d tags", MaxTagCount)))
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	totalBytes := 0
+	for _, k := range keys {
+		v := tags[k]
+		totalBytes += len(k) + len(v)
+
+		keyPath := fldPath.Key(k)
+		for _, e := range ValidateQualifiedName(k) {
+			allErrs = append(allErrs, field.Invalid(keyPath, k, e))
+		}
+		for _, e := range ValidateLabelValue(v) {
+			allErrs = append(allErrs, field.Invalid(keyPath, v, e))
+		}
+	}
+
+	if totalBytes > MaxTagBytes {
+		allErrs = append(allErrs, field.Invalid(fldPath, totalBytes, fmt.Sprintf("tags must not exceed %d total bytes", MaxTagBytes)))
+	}
+
+	return allErrs
+}
@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pabotesu/valon/valonctl/pkg/validation/field"
+)
+
+func TestValidateQualifiedName(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"bare name", "env", false},
+		{"prefixed name", "team.example.com/owner", false},
+		{"empty", "", true},
+		{"empty prefix", "/owner", true},
+		{"too many slashes", "a/b/c", true},
+		{"invalid prefix", "EXAMPLE/owner", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateQualifiedName(tt.value)
+			if (len(errs) > 0) != tt.wantErr {
+				t.Errorf("ValidateQualifiedName(%q) = %v, wantErr %v", tt.value, errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tags := map[string]string{
+		"env":      "prod",
+		"Bad Key":  "x",
+		"good-key": strings.Repeat("a", 100),
+	}
+
+	errs := ValidateTags(tags, field.NewPath("spec", "tags"))
+	if len(errs) < 2 {
+		t.Fatalf("ValidateTags() returned %d errors, want at least 2: %v", len(errs), errs)
+	}
+}
+
+func TestValidateTagsCountCap(t *testing.T) {
+	tags := make(map[string]string, MaxTagCount+1)
+	for i := 0; i < MaxTagCount+1; i++ {
+		tags[strings.Repeat("k", 1)+string(rune('a'+i%26))+string(rune('0'+i/26))] = "v"
+	}
+
+	errs := ValidateTags(tags, field.NewPath("spec", "tags"))
+	if len(errs) == 0 {
+		t.Errorf("ValidateTags() with %d tags returned no errors, want a MaxTagCount violation", len(tags))
+	}
+}
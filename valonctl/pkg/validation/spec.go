@@ -0,0 +1,89 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pabotesu/valon/valonctl/pkg/validation/field"
+)
+
+// AliasSpec is the full set of user-supplied fields an alias registration
+// validates together - what `valonctl peer add` and any future batch entry
+// point both need checked before anything is written to etcd.
+type AliasSpec struct {
+	Alias string
+	Zone  string
+
+	// Tags is free-form key/value metadata (e.g. "env": "prod",
+	// "team.example.com/owner": "netops"), validated by ValidateTags
+	// using the Kubernetes qualified-name/label-value grammar rather than
+	// the stricter DNS-1123 label grammar Alias itself uses - tags don't
+	// go on the wire as DNS labels, so they don't need to be that strict.
+	Tags      map[string]string
+	TargetIPs []string
+}
+
+// ValidateAliasSpec validates every field of spec and returns every
+// violation found, rather than stopping at the first one like ValidateAlias
+// does - so a CLI or API caller can report a whole invalid batch in one
+// response. fldPath is the root path to prefix onto every Error (typically
+// field.NewPath("spec")).
+func ValidateAliasSpec(spec *AliasSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateAliasField(spec.Alias, fldPath.Child("alias"))...)
+
+	zonePath := fldPath.Child("zone")
+	if spec.Zone == "" {
+		allErrs = append(allErrs, field.Required(zonePath, "zone cannot be empty"))
+	} else {
+		if err := ValidateFQDN(spec.Alias, spec.Zone); err != nil {
+			allErrs = append(allErrs, field.Invalid(zonePath, spec.Zone, err.Error()))
+		}
+		for _, e := range IsReservedZone(spec.Zone, DefaultReservedPolicy) {
+			allErrs = append(allErrs, field.Invalid(zonePath, spec.Zone, e))
+		}
+	}
+
+	allErrs = append(allErrs, ValidateTags(spec.Tags, fldPath.Child("tags"))...)
+
+	for i, ip := range spec.TargetIPs {
+		if net.ParseIP(ip) == nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("targetIPs").Index(i), ip, "must be a valid IP address"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateAliasField runs the same checks ValidateAlias does, but as an
+// ErrorList keyed to aliasPath instead of a fail-fast error.
+func validateAliasField(alias string, aliasPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if alias == "" {
+		errs = append(errs, field.Required(aliasPath, "alias cannot be empty"))
+		return errs
+	}
+
+	lower := strings.ToLower(alias)
+
+	if len(lower) > MaxAliasLength {
+		errs = append(errs, field.Invalid(aliasPath, alias, fmt.Sprintf("must be no more than %d characters", MaxAliasLength)))
+	}
+
+	for _, e := range IsDNS1123Label(lower) {
+		errs = append(errs, field.Invalid(aliasPath, alias, e))
+	}
+
+	for _, e := range IsReservedPrefix(lower, DefaultReservedPolicy) {
+		errs = append(errs, field.Invalid(aliasPath, alias, e))
+	}
+
+	for _, e := range IsReservedLabel(lower, DefaultReservedPolicy) {
+		errs = append(errs, field.Invalid(aliasPath, alias, e))
+	}
+
+	return errs
+}
@@ -0,0 +1,34 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/pabotesu/valon/valonctl/pkg/validation/field"
+)
+
+func TestValidateAliasSpec(t *testing.T) {
+	spec := &AliasSpec{
+		Alias:     "-invalid",
+		Zone:      "valon.internal.",
+		Tags:      map[string]string{"Bad Key": "x"},
+		TargetIPs: []string{"not-an-ip"},
+	}
+
+	errs := ValidateAliasSpec(spec, field.NewPath("spec"))
+	if len(errs) < 3 {
+		t.Fatalf("ValidateAliasSpec() returned %d errors, want at least 3 (one per bad field): %v", len(errs), errs)
+	}
+}
+
+func TestValidateAliasSpecValid(t *testing.T) {
+	spec := &AliasSpec{
+		Alias:     "alice",
+		Zone:      "valon.internal.",
+		Tags:      map[string]string{"env": "prod", "team.example.com/owner": "netops"},
+		TargetIPs: []string{"100.64.0.1"},
+	}
+
+	if errs := ValidateAliasSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("ValidateAliasSpec() = %v, want no errors", errs)
+	}
+}
@@ -0,0 +1,304 @@
+// Package wgcfg is a typed, round-trippable representation of a WireGuard
+// interface configuration, modeled on the wireguard-go/tailscale wgcfg
+// design. It replaces building wg-quick INI text by hand with fmt.Printf:
+// Config is parsed from or marshaled to wg-quick INI via Parse/Marshal, and
+// converted to the userspace-API config string wireguard-go's IpcSet
+// expects via ToUAPI, so every command that reads or writes a WireGuard
+// config (peer add, peer showconf, peer import --from wg-quick) flows
+// through the same representation instead of three separate text formats.
+package wgcfg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Config is one WireGuard interface's full configuration: its own
+// [Interface] section plus every [Peer] it talks to.
+type Config struct {
+	PrivateKey wgtypes.Key
+	Addresses  []netip.Prefix
+	ListenPort int
+	MTU        int
+	DNS        []netip.Addr
+	Peers      []Peer
+}
+
+// Peer is one [Peer] stanza.
+type Peer struct {
+	PublicKey           wgtypes.Key
+	PresharedKey        *wgtypes.Key
+	AllowedIPs          []netip.Prefix
+	Endpoint            *net.UDPAddr
+	PersistentKeepalive time.Duration
+}
+
+// Marshal renders cfg as a wg-quick style INI.
+func (cfg Config) Marshal() ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("[Interface]\n")
+	if !isZeroKey(cfg.PrivateKey) {
+		fmt.Fprintf(&b, "PrivateKey = %s\n", cfg.PrivateKey.String())
+	}
+	if len(cfg.Addresses) > 0 {
+		addrs := make([]string, len(cfg.Addresses))
+		for i, a := range cfg.Addresses {
+			addrs[i] = a.String()
+		}
+		fmt.Fprintf(&b, "Address = %s\n", strings.Join(addrs, ", "))
+	}
+	if cfg.ListenPort != 0 {
+		fmt.Fprintf(&b, "ListenPort = %d\n", cfg.ListenPort)
+	}
+	if cfg.MTU != 0 {
+		fmt.Fprintf(&b, "MTU = %d\n", cfg.MTU)
+	}
+	if len(cfg.DNS) > 0 {
+		dns := make([]string, len(cfg.DNS))
+		for i, d := range cfg.DNS {
+			dns[i] = d.String()
+		}
+		fmt.Fprintf(&b, "DNS = %s\n", strings.Join(dns, ", "))
+	}
+
+	for _, p := range cfg.Peers {
+		b.WriteString("\n[Peer]\n")
+		fmt.Fprintf(&b, "PublicKey = %s\n", p.PublicKey.String())
+		if p.PresharedKey != nil {
+			fmt.Fprintf(&b, "PresharedKey = %s\n", p.PresharedKey.String())
+		}
+		if len(p.AllowedIPs) > 0 {
+			ips := make([]string, len(p.AllowedIPs))
+			for i, ip := range p.AllowedIPs {
+				ips[i] = ip.String()
+			}
+			fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(ips, ", "))
+		}
+		if p.Endpoint != nil {
+			fmt.Fprintf(&b, "Endpoint = %s\n", p.Endpoint.String())
+		}
+		if p.PersistentKeepalive != 0 {
+			fmt.Fprintf(&b, "PersistentKeepalive = %d\n", int(p.PersistentKeepalive.Seconds()))
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// Parse reads a wg-quick style INI into a Config. Comments (# and ;),
+// blank lines, and surrounding whitespace are ignored; AllowedIPs, Address,
+// and DNS accept comma-separated multi-value lists.
+func Parse(r io.Reader) (Config, error) {
+	var cfg Config
+	var curPeer *Peer
+	inPeer := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if curPeer != nil {
+				cfg.Peers = append(cfg.Peers, *curPeer)
+				curPeer = nil
+			}
+			inPeer = strings.EqualFold(strings.TrimSpace(line), "[Peer]")
+			if inPeer {
+				curPeer = &Peer{}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = unquote(strings.TrimSpace(value))
+
+		var err error
+		if inPeer && curPeer != nil {
+			err = parsePeerField(curPeer, key, value)
+		} else {
+			err = parseInterfaceField(&cfg, key, value)
+		}
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	if curPeer != nil {
+		cfg.Peers = append(cfg.Peers, *curPeer)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func parseInterfaceField(cfg *Config, key, value string) error {
+	switch strings.ToLower(key) {
+	case "privatekey":
+		k, err := wgtypes.ParseKey(value)
+		if err != nil {
+			return fmt.Errorf("invalid PrivateKey: %w", err)
+		}
+		cfg.PrivateKey = k
+
+	case "address":
+		for _, s := range splitList(value) {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return fmt.Errorf("invalid Address %q: %w", s, err)
+			}
+			cfg.Addresses = append(cfg.Addresses, p)
+		}
+
+	case "listenport":
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid ListenPort: %w", err)
+		}
+		cfg.ListenPort = port
+
+	case "mtu":
+		mtu, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid MTU: %w", err)
+		}
+		cfg.MTU = mtu
+
+	case "dns":
+		for _, s := range splitList(value) {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return fmt.Errorf("invalid DNS %q: %w", s, err)
+			}
+			cfg.DNS = append(cfg.DNS, addr)
+		}
+	}
+
+	return nil
+}
+
+func parsePeerField(p *Peer, key, value string) error {
+	switch strings.ToLower(key) {
+	case "publickey":
+		k, err := wgtypes.ParseKey(value)
+		if err != nil {
+			return fmt.Errorf("invalid PublicKey: %w", err)
+		}
+		p.PublicKey = k
+
+	case "presharedkey":
+		k, err := wgtypes.ParseKey(value)
+		if err != nil {
+			return fmt.Errorf("invalid PresharedKey: %w", err)
+		}
+		p.PresharedKey = &k
+
+	case "allowedips":
+		for _, s := range splitList(value) {
+			prefix, err := netip.ParsePrefix(s)
+			if err != nil {
+				return fmt.Errorf("invalid AllowedIPs %q: %w", s, err)
+			}
+			p.AllowedIPs = append(p.AllowedIPs, prefix)
+		}
+
+	case "endpoint":
+		addr, err := net.ResolveUDPAddr("udp", value)
+		if err != nil {
+			return fmt.Errorf("invalid Endpoint %q: %w", value, err)
+		}
+		p.Endpoint = addr
+
+	case "persistentkeepalive":
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid PersistentKeepalive: %w", err)
+		}
+		p.PersistentKeepalive = time.Duration(seconds) * time.Second
+	}
+
+	return nil
+}
+
+// ToUAPI renders cfg as the userspace-API configuration string accepted by
+// wireguard-go's device.Device.IpcSet (see
+// https://www.wireguard.com/xplatform/#configuration-protocol).
+func (cfg Config) ToUAPI() string {
+	var b strings.Builder
+
+	if !isZeroKey(cfg.PrivateKey) {
+		fmt.Fprintf(&b, "private_key=%s\n", hexKey(cfg.PrivateKey[:]))
+	}
+	if cfg.ListenPort != 0 {
+		fmt.Fprintf(&b, "listen_port=%d\n", cfg.ListenPort)
+	}
+
+	for _, p := range cfg.Peers {
+		fmt.Fprintf(&b, "public_key=%s\n", hexKey(p.PublicKey[:]))
+		if p.PresharedKey != nil {
+			fmt.Fprintf(&b, "preshared_key=%s\n", hexKey(p.PresharedKey[:]))
+		}
+		if p.Endpoint != nil {
+			fmt.Fprintf(&b, "endpoint=%s\n", p.Endpoint.String())
+		}
+		for _, ip := range p.AllowedIPs {
+			fmt.Fprintf(&b, "allowed_ip=%s\n", ip.String())
+		}
+		if p.PersistentKeepalive != 0 {
+			fmt.Fprintf(&b, "persistent_keepalive_interval=%d\n", int(p.PersistentKeepalive.Seconds()))
+		}
+	}
+
+	return b.String()
+}
+
+func hexKey(key []byte) string {
+	const hextable = "0123456789abcdef"
+	out := make([]byte, len(key)*2)
+	for i, c := range key {
+		out[i*2] = hextable[c>>4]
+		out[i*2+1] = hextable[c&0x0f]
+	}
+	return string(out)
+}
+
+func isZeroKey(k wgtypes.Key) bool {
+	return k == wgtypes.Key{}
+}
+
+func splitList(value string) []string {
+	parts := strings.Split(value, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if s := strings.TrimSpace(p); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// unquote strips a single pair of surrounding double quotes, e.g. around a
+// PresharedKey value, the one field wg-quick conventionally quotes.
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
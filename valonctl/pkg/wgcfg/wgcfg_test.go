@@ -0,0 +1,162 @@
+package wgcfg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func mustKey(t *testing.T) wgtypes.Key {
+	t.Helper()
+	k, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey: %v", err)
+	}
+	return k
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	priv := mustKey(t)
+	pub := mustKey(t)
+	psk := mustKey(t)
+
+	cfg := Config{
+		PrivateKey: priv,
+		ListenPort: 51820,
+		MTU:        1420,
+		Peers: []Peer{
+			{
+				PublicKey:           pub,
+				PresharedKey:        &psk,
+				PersistentKeepalive: 25 * time.Second,
+			},
+		},
+	}
+
+	data, err := cfg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	got, err := Parse(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if got.PrivateKey != priv {
+		t.Errorf("PrivateKey = %v, want %v", got.PrivateKey, priv)
+	}
+	if got.ListenPort != 51820 {
+		t.Errorf("ListenPort = %d, want 51820", got.ListenPort)
+	}
+	if len(got.Peers) != 1 || got.Peers[0].PublicKey != pub {
+		t.Fatalf("Peers = %+v, want one peer with PublicKey %v", got.Peers, pub)
+	}
+	if got.Peers[0].PresharedKey == nil || *got.Peers[0].PresharedKey != psk {
+		t.Errorf("PresharedKey = %v, want %v", got.Peers[0].PresharedKey, psk)
+	}
+	if got.Peers[0].PersistentKeepalive != 25*time.Second {
+		t.Errorf("PersistentKeepalive = %v, want 25s", got.Peers[0].PersistentKeepalive)
+	}
+}
+
+func TestParseEdgeCases(t *testing.T) {
+	pub := mustKey(t)
+
+	input := `
+# a leading comment
+; a semicolon comment too
+
+[Interface]
+Address = 10.0.0.1/32, fd00::1/128
+MTU = 1420
+
+   [Peer]
+PublicKey   =   ` + pub.String() + `
+AllowedIPs = 10.0.0.2/32,  10.0.0.3/32
+Endpoint = 192.0.2.1:51820
+PersistentKeepalive = 25
+`
+
+	cfg, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(cfg.Addresses) != 2 {
+		t.Fatalf("Addresses = %+v, want 2 entries", cfg.Addresses)
+	}
+	if cfg.MTU != 1420 {
+		t.Errorf("MTU = %d, want 1420", cfg.MTU)
+	}
+	if len(cfg.Peers) != 1 {
+		t.Fatalf("Peers = %+v, want 1 peer", cfg.Peers)
+	}
+
+	p := cfg.Peers[0]
+	if p.PublicKey != pub {
+		t.Errorf("PublicKey = %v, want %v", p.PublicKey, pub)
+	}
+	if len(p.AllowedIPs) != 2 {
+		t.Errorf("AllowedIPs = %+v, want 2 entries", p.AllowedIPs)
+	}
+	if p.Endpoint == nil || p.Endpoint.String() != "192.0.2.1:51820" {
+		t.Errorf("Endpoint = %v, want 192.0.2.1:51820", p.Endpoint)
+	}
+	if p.PersistentKeepalive != 25*time.Second {
+		t.Errorf("PersistentKeepalive = %v, want 25s", p.PersistentKeepalive)
+	}
+}
+
+func TestParseQuotedPresharedKey(t *testing.T) {
+	pub := mustKey(t)
+	psk := mustKey(t)
+
+	input := `[Peer]
+PublicKey = ` + pub.String() + `
+PresharedKey = "` + psk.String() + `"
+`
+
+	cfg, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(cfg.Peers) != 1 || cfg.Peers[0].PresharedKey == nil {
+		t.Fatalf("Peers = %+v, want one peer with a PresharedKey", cfg.Peers)
+	}
+	if *cfg.Peers[0].PresharedKey != psk {
+		t.Errorf("PresharedKey = %v, want %v", *cfg.Peers[0].PresharedKey, psk)
+	}
+}
+
+func TestParseInvalidKey(t *testing.T) {
+	_, err := Parse(strings.NewReader("[Peer]\nPublicKey = not-a-valid-key\n"))
+	if err == nil {
+		t.Fatal("expected an error for an invalid PublicKey")
+	}
+}
+
+func TestToUAPI(t *testing.T) {
+	priv := mustKey(t)
+	pub := mustKey(t)
+
+	cfg := Config{
+		PrivateKey: priv,
+		Peers: []Peer{
+			{PublicKey: pub, PersistentKeepalive: 25 * time.Second},
+		},
+	}
+
+	uapi := cfg.ToUAPI()
+	if !strings.Contains(uapi, "private_key=") {
+		t.Errorf("ToUAPI() = %q, want a private_key line", uapi)
+	}
+	if !strings.Contains(uapi, "public_key=") {
+		t.Errorf("ToUAPI() = %q, want a public_key line", uapi)
+	}
+	if !strings.Contains(uapi, "persistent_keepalive_interval=25") {
+		t.Errorf("ToUAPI() = %q, want persistent_keepalive_interval=25", uapi)
+	}
+}
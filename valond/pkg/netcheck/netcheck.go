@@ -0,0 +1,381 @@
+// Package netcheck implements STUN-based reflexive endpoint discovery and
+// NAT-type classification for a VALON peer, in the spirit of Tailscale's
+// netcheck: a lightweight, locally-run probe that tells a peer what its own
+// public IP:port looks like from the outside, and how predictable that
+// mapping is.
+package netcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// NATType describes how a NAT device maps and filters outbound traffic.
+type NATType string
+
+const (
+	// NATOpen means no NAT was detected (reflexive port == local port).
+	NATOpen NATType = "open"
+	// NATEIM is Endpoint-Independent Mapping: the reflexive port is the same
+	// regardless of which STUN server/port was asked, the easy case for
+	// hole punching.
+	NATEIM NATType = "eim"
+	// NATEDM is Endpoint-Dependent Mapping: the reflexive port changes
+	// depending on the destination, making most hole punching unreliable.
+	NATEDM NATType = "edm"
+	// NATSymmetric is the strictest, most hostile case: every destination
+	// gets its own mapping. A relay is typically required.
+	NATSymmetric NATType = "symmetric"
+	// NATUnknown means classification could not be completed (e.g. one or
+	// more STUN servers were unreachable).
+	NATUnknown NATType = "unknown"
+)
+
+// DefaultServers is the built-in list of public STUN servers used when the
+// caller does not supply its own.
+var DefaultServers = []string{
+	"stun.l.google.com:19302",
+	"stun1.l.google.com:19302",
+}
+
+// Config controls how a Checker probes the network.
+type Config struct {
+	Servers []string      // STUN servers to probe, host:port form
+	Timeout time.Duration // per-probe timeout (default: 2s)
+}
+
+// Report is the result of a single netcheck run.
+type Report struct {
+	ReflexiveEndpoint string  // best-guess "IP:PORT" as seen from the outside
+	NATType           NATType // classification of the NAT behavior
+}
+
+// Checker runs STUN probes against a configured server list.
+type Checker struct {
+	cfg Config
+}
+
+// NewChecker creates a Checker with the given configuration, applying
+// defaults for any zero-valued fields.
+func NewChecker(cfg Config) *Checker {
+	if len(cfg.Servers) == 0 {
+		cfg.Servers = DefaultServers
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	return &Checker{cfg: cfg}
+}
+
+// Run performs the netcheck: it sends STUN Binding requests to at least two
+// servers, and - where the first server to answer supports RFC 5780 NAT
+// Behavior Discovery - a second port on that same server via its advertised
+// OTHER-ADDRESS, all from the same local socket WireGuard listens on. It
+// then compares the reflexive addresses to classify the NAT's mapping
+// behavior.
+//
+// localPort should be the port wgctrl reports the WireGuard device is
+// listening on, so the reflexive mapping observed here matches the mapping
+// peers will actually see on the wire. If localPort is 0, an ephemeral port
+// is used instead (useful for a standalone connectivity check).
+func (c *Checker) Run(ctx context.Context, localPort int) (*Report, error) {
+	if len(c.cfg.Servers) < 2 {
+		return nil, fmt.Errorf("netcheck requires at least 2 STUN servers, got %d", len(c.cfg.Servers))
+	}
+
+	results := make([]*bindingResult, 0, len(c.cfg.Servers))
+	for _, server := range c.cfg.Servers {
+		res, err := c.bindTo(ctx, server, localPort)
+		if err != nil {
+			continue
+		}
+		results = append(results, res)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no STUN server responded")
+	}
+
+	// If the first server to answer advertised a second address/port it
+	// also listens on (RFC 5780 OTHER-ADDRESS), run the two further probes
+	// RFC 5780 §4.3 defines to isolate address-dependence from
+	// port-dependence: Test II holds the port fixed and only changes the
+	// destination IP; Test III (OTHER-ADDRESS itself) changes both. Neither
+	// alone tells classify what it needs - Test III still changes the IP
+	// too, so a port change there doesn't by itself prove port-dependence.
+	var testII, testIII *bindingResult
+	if results[0].other != "" {
+		if _, origPort, err := net.SplitHostPort(results[0].server); err == nil {
+			if otherHost, _, err := net.SplitHostPort(results[0].other); err == nil {
+				if res, err := c.bindTo(ctx, net.JoinHostPort(otherHost, origPort), localPort); err == nil {
+					testII = res
+				}
+			}
+		}
+		if res, err := c.bindTo(ctx, results[0].other, localPort); err == nil {
+			testIII = res
+		}
+	}
+
+	report := &Report{
+		ReflexiveEndpoint: results[0].mapped,
+		NATType:           classify(results, testII, testIII, localPort),
+	}
+	return report, nil
+}
+
+type bindingResult struct {
+	server string
+	mapped string // reflexive "IP:PORT" as reported by the server
+	other  string // OTHER-ADDRESS (RFC 5780), a second address/port the same server also listens on; empty if not advertised
+}
+
+// classify compares the reflexive mappings seen across servers (and, if
+// available, the RFC 5780 Test II/III probes run against a second address
+// on the server that answered first) to decide how the NAT behaves:
+//   - if there's only one usable result, classification stays unknown
+//   - if localPort is non-zero and the first reflexive port equals it, there's
+//     no NAT in the path (the reflexive IP is not compared)
+//   - if testII and testIII are available, RFC 5780 §4.3 applies directly:
+//     testII changes only the destination IP relative to the first probe,
+//     so if its port still matches, the mapping is endpoint-independent
+//     (EIM); otherwise testIII additionally changes the destination port,
+//     so comparing testII against testIII isolates whether that further
+//     change moves the port too (Symmetric) or not (EDM)
+//   - without those probes (the first server to answer didn't advertise
+//     OTHER-ADDRESS), classification falls back to the coarser guess of
+//     treating as many distinct ports as servers probed as Symmetric, and
+//     anything else that isn't address-independent as EDM
+func classify(results []*bindingResult, testII, testIII *bindingResult, localPort int) NATType {
+	if len(results) < 2 {
+		return NATUnknown
+	}
+
+	_, port0, err := net.SplitHostPort(results[0].mapped)
+	if err == nil && localPort != 0 && port0 == portString(localPort) {
+		return NATOpen
+	}
+
+	ports := make(map[string]bool, len(results))
+	for _, r := range results {
+		_, port, err := net.SplitHostPort(r.mapped)
+		if err != nil {
+			continue
+		}
+		ports[port] = true
+	}
+	addressDependent := len(ports) > 1
+
+	if err == nil && testII != nil && testIII != nil {
+		if _, portII, errII := net.SplitHostPort(testII.mapped); errII == nil {
+			if portII == port0 {
+				// Same port with only the destination IP changed: the
+				// mapping doesn't depend on the destination at all.
+				return NATEIM
+			}
+			if _, portIII, errIII := net.SplitHostPort(testIII.mapped); errIII == nil {
+				if portIII == portII {
+					// Changing the destination port further (on top of
+					// the IP change already in testII) didn't move the
+					// mapping again: address-dependent, not port-dependent.
+					return NATEDM
+				}
+				return NATSymmetric
+			}
+		}
+	}
+
+	switch {
+	case !addressDependent:
+		return NATEIM
+	case len(ports) == len(results):
+		return NATSymmetric
+	default:
+		return NATEDM
+	}
+}
+
+func portString(port int) string {
+	return fmt.Sprintf("%d", port)
+}
+
+// bindTo sends a single STUN Binding Request (RFC 5389) to server and parses
+// the XOR-MAPPED-ADDRESS (and, if present, OTHER-ADDRESS) attributes out of
+// the response.
+func (c *Checker) bindTo(ctx context.Context, server string, localPort int) (*bindingResult, error) {
+	raddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", server, err)
+	}
+
+	var laddr *net.UDPAddr
+	if localPort != 0 {
+		laddr = &net.UDPAddr{Port: localPort}
+	}
+
+	conn, err := net.DialUDP("udp", laddr, raddr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(c.cfg.Timeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	req, txID, err := newBindingRequest()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return nil, fmt.Errorf("write to %s: %w", server, err)
+	}
+
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("read from %s: %w", server, err)
+	}
+
+	mapped, other, err := parseBindingResponse(buf[:n], txID)
+	if err != nil {
+		return nil, fmt.Errorf("parse response from %s: %w", server, err)
+	}
+
+	return &bindingResult{server: server, mapped: mapped, other: other}, nil
+}
+
+const (
+	stunMagicCookie       = 0x2112A442
+	stunBindingRequest    = 0x0001
+	stunBindingSuccess    = 0x0101
+	stunAttrMappedAddr    = 0x0001
+	stunAttrXorMappedAddr = 0x0020
+	// stunAttrOtherAddr is OTHER-ADDRESS (RFC 5780 §7.3): a second
+	// address/port the same server also listens on, used for NAT behavior
+	// discovery Test II instead of the deprecated RFC 3489 CHANGE-REQUEST.
+	stunAttrOtherAddr = 0x802C
+)
+
+// newBindingRequest builds a minimal STUN Binding Request message and
+// returns it along with the 12-byte transaction ID so the response can be
+// matched.
+func newBindingRequest() ([]byte, [12]byte, error) {
+	var txID [12]byte
+	if _, err := rand.Read(txID[:]); err != nil {
+		return nil, txID, fmt.Errorf("generate transaction ID: %w", err)
+	}
+
+	msg := make([]byte, 20)
+	binary.BigEndian.PutUint16(msg[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(msg[2:4], 0) // message length, no attributes
+	binary.BigEndian.PutUint32(msg[4:8], stunMagicCookie)
+	copy(msg[8:20], txID[:])
+
+	return msg, txID, nil
+}
+
+// parseBindingResponse extracts the reflexive "IP:PORT" from a STUN Binding
+// Success Response, preferring XOR-MAPPED-ADDRESS (RFC 5389) and falling
+// back to the legacy MAPPED-ADDRESS attribute. It also returns OTHER-ADDRESS
+// (RFC 5780 Test II) if the server advertised one, so the caller can probe a
+// second address/port on the same server; other is "" if the server didn't
+// include it.
+func parseBindingResponse(data []byte, txID [12]byte) (mapped, other string, err error) {
+	if len(data) < 20 {
+		return "", "", fmt.Errorf("response too short: %d bytes", len(data))
+	}
+
+	msgType := binary.BigEndian.Uint16(data[0:2])
+	if msgType != stunBindingSuccess {
+		return "", "", fmt.Errorf("unexpected STUN message type: 0x%04x", msgType)
+	}
+	if binary.BigEndian.Uint32(data[4:8]) != stunMagicCookie {
+		return "", "", fmt.Errorf("bad STUN magic cookie")
+	}
+	if string(data[8:20]) != string(txID[:]) {
+		return "", "", fmt.Errorf("STUN transaction ID mismatch")
+	}
+
+	msgLen := int(binary.BigEndian.Uint16(data[2:4]))
+	attrs := data[20:]
+	if len(attrs) < msgLen {
+		return "", "", fmt.Errorf("truncated STUN attributes")
+	}
+	attrs = attrs[:msgLen]
+
+	var mappedAddr, xorMappedAddr, otherAddr string
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:2])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if len(attrs) < 4+attrLen {
+			break
+		}
+		val := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunAttrXorMappedAddr:
+			if addr, err := decodeXorMappedAddress(val, txID); err == nil {
+				xorMappedAddr = addr
+			}
+		case stunAttrMappedAddr:
+			if addr, err := decodeMappedAddress(val); err == nil {
+				mappedAddr = addr
+			}
+		case stunAttrOtherAddr:
+			// OTHER-ADDRESS uses the same plain (non-XOR) encoding as
+			// MAPPED-ADDRESS (RFC 5780 §7.3).
+			if addr, err := decodeMappedAddress(val); err == nil {
+				otherAddr = addr
+			}
+		}
+
+		// Attributes are padded to a multiple of 4 bytes.
+		padded := (attrLen + 3) &^ 3
+		attrs = attrs[4+padded:]
+	}
+
+	if xorMappedAddr != "" {
+		return xorMappedAddr, otherAddr, nil
+	}
+	if mappedAddr != "" {
+		return mappedAddr, otherAddr, nil
+	}
+	return "", "", fmt.Errorf("no MAPPED-ADDRESS attribute found")
+}
+
+func decodeMappedAddress(val []byte) (string, error) {
+	if len(val) < 8 || val[1] != 0x01 {
+		return "", fmt.Errorf("unsupported MAPPED-ADDRESS family")
+	}
+	port := binary.BigEndian.Uint16(val[2:4])
+	ip := net.IP(val[4:8])
+	return fmt.Sprintf("%s:%d", ip.String(), port), nil
+}
+
+func decodeXorMappedAddress(val []byte, txID [12]byte) (string, error) {
+	if len(val) < 8 || val[1] != 0x01 {
+		return "", fmt.Errorf("unsupported XOR-MAPPED-ADDRESS family")
+	}
+
+	xport := binary.BigEndian.Uint16(val[2:4])
+	port := xport ^ uint16(stunMagicCookie>>16)
+
+	cookie := make([]byte, 4)
+	binary.BigEndian.PutUint32(cookie, stunMagicCookie)
+
+	ipBytes := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		ipBytes[i] = val[4+i] ^ cookie[i]
+	}
+
+	return fmt.Sprintf("%s:%d", net.IP(ipBytes).String(), port), nil
+}
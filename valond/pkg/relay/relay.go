@@ -0,0 +1,225 @@
+// Package relay implements a small UDP relay server for peers that have no
+// working direct path to each other (symmetric NAT on both ends, or
+// CGNAT). A peer designated `role: relay` runs this server; other peers
+// whose direct connection attempts are stuck get their etcd
+// endpoints/nated endpoint rewritten by the CoreDNS plugin to point at the
+// relay instead (see coredns-plugin/valon/relay.go).
+//
+// Wire format: every UDP datagram sent to the relay starts with a 1-byte
+// packet type, followed by a 32-byte WireGuard public key:
+//
+//	[[0x01] [dest pubkey (32)] [wg payload...]]  - forward payload to dest
+//	[[0x02] [sender pubkey (32)]]                 - register/keepalive beacon
+//
+// A registered peer's most recently observed source address is used as the
+// forwarding target for packets addressed to its pubkey. Entries expire
+// after RegistrationTTL of inactivity.
+package relay
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	packetTypeForward  byte = 0x01
+	packetTypeRegister byte = 0x02
+
+	pubkeySize = 32
+
+	// RegistrationTTL bounds how long a relay keeps forwarding to a peer's
+	// last known address without hearing another register beacon from it.
+	RegistrationTTL = 2 * time.Minute
+
+	maxPacketSize = 65535
+)
+
+// Server is a UDP relay server. Use NewServer then Serve (blocking) from a
+// goroutine, and ListenAndServeHealth for the metrics endpoint.
+type Server struct {
+	conn *net.UDPConn
+
+	mu       sync.RWMutex
+	registry map[string]*registration // key: base64 pubkey
+
+	metrics metrics
+}
+
+type registration struct {
+	addr     *net.UDPAddr
+	lastSeen time.Time
+}
+
+// metrics holds relay counters. Fields are accessed via atomic ops so Serve
+// can update them from the packet-processing hot path without a lock.
+type metrics struct {
+	packetsForwarded uint64
+	bytesForwarded   uint64
+
+	mu         sync.Mutex
+	peerBytes  map[string]uint64
+	peerPacket map[string]uint64
+}
+
+// PeerMetrics is a point-in-time snapshot of one destination pubkey's relay
+// traffic, returned by Server.Snapshot and served at /health.
+type PeerMetrics struct {
+	Pubkey           string `json:"pubkey"`
+	PacketsForwarded uint64 `json:"packets_forwarded"`
+	BytesForwarded   uint64 `json:"bytes_forwarded"`
+}
+
+// Snapshot is the full relay metrics report served at /health.
+type Snapshot struct {
+	PacketsForwarded uint64        `json:"packets_forwarded"`
+	BytesForwarded   uint64        `json:"bytes_forwarded"`
+	RegisteredPeers  int           `json:"registered_peers"`
+	Peers            []PeerMetrics `json:"peers"`
+}
+
+// EncodeRegisterBeacon builds the UDP payload a relayed peer sends to keep
+// its registration alive (see the register/keepalive case in Serve). pubkey
+// must be the peer's raw 32-byte WireGuard public key.
+func EncodeRegisterBeacon(pubkey [pubkeySize]byte) []byte {
+	pkt := make([]byte, 1+pubkeySize)
+	pkt[0] = packetTypeRegister
+	copy(pkt[1:], pubkey[:])
+	return pkt
+}
+
+// NewServer binds a UDP socket on listenAddr (e.g. "0.0.0.0:51821") ready to
+// relay traffic.
+func NewServer(listenAddr string) (*Server, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid relay listen address %q: %w", listenAddr, err)
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", listenAddr, err)
+	}
+
+	return &Server{
+		conn:     conn,
+		registry: make(map[string]*registration),
+		metrics: metrics{
+			peerBytes:  make(map[string]uint64),
+			peerPacket: make(map[string]uint64),
+		},
+	}, nil
+}
+
+// Serve reads and relays packets until the connection is closed. It is
+// expected to run in its own goroutine.
+func (s *Server) Serve() error {
+	buf := make([]byte, maxPacketSize)
+
+	for {
+		n, addr, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return fmt.Errorf("relay read failed: %w", err)
+		}
+		s.handlePacket(buf[:n], addr)
+	}
+}
+
+// Close shuts down the relay's UDP socket.
+func (s *Server) Close() error {
+	return s.conn.Close()
+}
+
+func (s *Server) handlePacket(pkt []byte, from *net.UDPAddr) {
+	if len(pkt) < 1+pubkeySize {
+		return
+	}
+
+	packetType := pkt[0]
+	pubkey := base64.StdEncoding.EncodeToString(pkt[1 : 1+pubkeySize])
+
+	switch packetType {
+	case packetTypeRegister:
+		s.register(pubkey, from)
+
+	case packetTypeForward:
+		s.forward(pubkey, pkt[1+pubkeySize:])
+
+	default:
+		// Unknown packet type; drop silently, same as an unreachable peer.
+	}
+}
+
+func (s *Server) register(pubkey string, from *net.UDPAddr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registry[pubkey] = &registration{addr: from, lastSeen: time.Now()}
+}
+
+func (s *Server) forward(destPubkey string, payload []byte) {
+	s.mu.RLock()
+	reg, ok := s.registry[destPubkey]
+	s.mu.RUnlock()
+
+	if !ok || time.Since(reg.lastSeen) > RegistrationTTL {
+		return
+	}
+
+	if _, err := s.conn.WriteToUDP(payload, reg.addr); err != nil {
+		log.Printf("[relay] Failed to forward %d bytes to %s: %v", len(payload), destPubkey[:16]+"...", err)
+		return
+	}
+
+	atomic.AddUint64(&s.metrics.packetsForwarded, 1)
+	atomic.AddUint64(&s.metrics.bytesForwarded, uint64(len(payload)))
+
+	s.metrics.mu.Lock()
+	s.metrics.peerPacket[destPubkey]++
+	s.metrics.peerBytes[destPubkey] += uint64(len(payload))
+	s.metrics.mu.Unlock()
+}
+
+// Snapshot returns a point-in-time copy of the relay's traffic metrics.
+func (s *Server) Snapshot() Snapshot {
+	s.metrics.mu.Lock()
+	defer s.metrics.mu.Unlock()
+
+	peers := make([]PeerMetrics, 0, len(s.metrics.peerPacket))
+	for pubkey, packets := range s.metrics.peerPacket {
+		peers = append(peers, PeerMetrics{
+			Pubkey:           pubkey,
+			PacketsForwarded: packets,
+			BytesForwarded:   s.metrics.peerBytes[pubkey],
+		})
+	}
+
+	s.mu.RLock()
+	registered := len(s.registry)
+	s.mu.RUnlock()
+
+	return Snapshot{
+		PacketsForwarded: atomic.LoadUint64(&s.metrics.packetsForwarded),
+		BytesForwarded:   atomic.LoadUint64(&s.metrics.bytesForwarded),
+		RegisteredPeers:  registered,
+		Peers:            peers,
+	}
+}
+
+// ListenAndServeHealth starts an HTTP server exposing the relay's metrics
+// at GET /health, mirroring the CoreDNS plugin's own /health endpoint.
+func (s *Server) ListenAndServeHealth(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Snapshot())
+	})
+
+	log.Printf("[relay] Health endpoint listening on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}